@@ -20,6 +20,8 @@ type Config struct {
 	Logging logger.Config `yaml:"logging"`
 	Ubuntu  UbuntuConfig  `yaml:"ubuntu"`
 	Phone   PhoneConfig   `yaml:"phone"`
+	Monitor MonitorConfig `yaml:"monitor"`
+	Host    HostConfig    `yaml:"host"`
 }
 
 // ServerConfig 服务器配置
@@ -38,6 +40,19 @@ type GRPCConfig struct {
 		Timeout             int  `yaml:"timeout"`
 		PermitWithoutStream bool `yaml:"permit_without_stream"`
 	} `yaml:"keepalive"`
+	TLS TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig gRPC TLS配置，cert_file为空时不启用TLS
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"`      // 服务端证书
+	KeyFile      string `yaml:"key_file"`       // 服务端私钥
+	ClientCAFile string `yaml:"client_ca_file"` // 校验客户端证书的CA，非空时强制mTLS
+}
+
+// MutualTLSEnabled 是否启用了客户端证书校验
+func (c TLSConfig) MutualTLSEnabled() bool {
+	return c.CertFile != "" && c.ClientCAFile != ""
 }
 
 // UbuntuConfig Ubuntu服务器配置
@@ -50,10 +65,86 @@ type UbuntuConfig struct {
 
 // PhoneConfig 云手机操作配置
 type PhoneConfig struct {
-	ADBPort          int     `yaml:"adb_port"`           // ADB端口
-	PingTimeout      int     `yaml:"ping_timeout"`       // Ping超时时间（秒）
-	ADBTimeout       int     `yaml:"adb_timeout"`        // ADB超时时间（秒）
-	LatencyThreshold float64 `yaml:"latency_threshold"` // Ping延迟阈值（毫秒）
+	ADBPort                  int                 `yaml:"adb_port"`                    // ADB端口
+	PingTimeout              int                 `yaml:"ping_timeout"`                // Ping超时时间（秒）
+	ADBTimeout               int                 `yaml:"adb_timeout"`                 // ADB超时时间（秒）
+	LatencyThreshold         float64             `yaml:"latency_threshold"`           // Ping延迟阈值（毫秒）
+	ShellIdleTimeout         int                 `yaml:"shell_idle_timeout"`          // 交互式Shell空闲超时时间（秒），按客户端最后一次输入计算
+	ShellTranscriptDir       string              `yaml:"shell_transcript_dir"`        // 交互式Shell会话记录目录
+	StreamCommandMaxTimeout  int                 `yaml:"stream_command_max_timeout"`  // 流式命令最长执行时间（秒），客户端未指定或超过时按该值执行
+	CommandPolicy            CommandPolicyConfig `yaml:"command_policy"`              // 云手机命令执行策略
+	MaxPushFileSize          int64               `yaml:"max_push_file_size"`          // 推送文件大小上限（字节），0表示不限制
+	MaxPullFileSize          int64               `yaml:"max_pull_file_size"`          // 拉取文件大小上限（字节），0表示不限制
+	FileChunkSize            int                 `yaml:"file_chunk_size"`             // 文件传输分块大小（字节）
+	PackageInstallTimeout    int                 `yaml:"package_install_timeout"`     // APK安装超时时间（秒）
+	ScreenRecordMaxDuration  int                 `yaml:"screen_record_max_duration"`  // 录屏时长上限（秒），不超过180
+	ScreenRecordMaxSize      int64               `yaml:"screen_record_max_size"`      // 单次录屏数据大小上限（字节），0表示不限制
+	BootWaitTimeout          int                 `yaml:"boot_wait_timeout"`           // 重启后等待开机完成超时时间（秒）
+	BatchMaxDevices          int                 `yaml:"batch_max_devices"`           // 单次批量操作设备数上限
+	BatchMaxConcurrency      int                 `yaml:"batch_max_concurrency"`       // 批量操作并发上限
+	BatchTimeout             int                 `yaml:"batch_timeout"`               // 批量操作默认整体超时时间（秒）
+	DeviceLock               DeviceLockConfig    `yaml:"device_lock"`                 // 按设备加锁配置
+	ADBKey                   ADBKeyConfig        `yaml:"adb_key"`                     // 运维方ADB密钥
+	DegradedLossThreshold    float64             `yaml:"degraded_loss_threshold"`     // 丢包率达到该值（%）判定为网络降级
+	UnreachableLossThreshold float64             `yaml:"unreachable_loss_threshold"`  // 丢包率达到该值（%）判定为不可达，0表示仅全部丢包时不可达
+	BandwidthTestMaxSize     int64               `yaml:"bandwidth_test_max_size"`     // 带宽测试每个方向传输数据量上限（字节），0表示使用默认值64MB
+	BandwidthTestMaxDuration int                 `yaml:"bandwidth_test_max_duration"` // 带宽测试每个方向传输时长上限（秒），0表示使用默认值10秒
+}
+
+// ADBKeyConfig 运维方ADB密钥配置
+type ADBKeyConfig struct {
+	KeyPath           string   `yaml:"key_path"`            // 当前私钥路径（PEM），可指向secrets挂载，为空时使用adb自动生成的密钥
+	PreviousKeyPaths  []string `yaml:"previous_key_paths"`  // 轮换中的历史私钥路径，继续用于连接尚未更新公钥的设备
+	GenerateIfMissing bool     `yaml:"generate_if_missing"` // 私钥不存在时是否自动生成
+}
+
+// DeviceLockConfig 云手机操作加锁配置
+type DeviceLockConfig struct {
+	Enabled       bool `yaml:"enabled"`         // 是否按设备串行化操作
+	MaxQueueDepth int  `yaml:"max_queue_depth"` // 每台设备的最大排队数
+	WaitTimeout   int  `yaml:"wait_timeout"`    // 等待设备锁超时时间（秒）
+}
+
+// CommandPolicyConfig 云手机命令执行策略配置
+type CommandPolicyConfig struct {
+	Enabled        bool                            `yaml:"enabled"`         // 是否启用命令策略
+	DefaultProfile string                          `yaml:"default_profile"` // 未匹配到调用方时使用的策略档案
+	Callers        map[string]string               `yaml:"callers"`         // 客户端证书CN -> 策略档案名，需启用mTLS
+	Profiles       map[string]CommandProfileConfig `yaml:"profiles"`        // 策略档案
+}
+
+// CommandProfileConfig 命令策略档案
+type CommandProfileConfig struct {
+	DefaultAction         string   `yaml:"default_action"`          // 未命中任何规则时的动作: allow / deny
+	AllowPatterns         []string `yaml:"allow_patterns"`          // 允许的命令正则（匹配单条子命令）
+	DenyPatterns          []string `yaml:"deny_patterns"`           // 拒绝的命令正则，优先于允许规则
+	ArgPattern            string   `yaml:"arg_pattern"`             // 每个参数必须匹配的正则，为空不校验
+	MaxCommandLength      int      `yaml:"max_command_length"`      // 命令最大长度，0表示不限制
+	AllowShellOperators   bool     `yaml:"allow_shell_operators"`   // 是否允许管道、命令串联和重定向
+	AllowSubstitution     bool     `yaml:"allow_substitution"`      // 是否允许命令替换 $(...) 和反引号
+	AllowInteractiveShell bool     `yaml:"allow_interactive_shell"` // 是否允许打开交互式Shell
+	AllowedOperations     []string `yaml:"allowed_operations"`      // 允许的设备变更操作: reboot / install_package / uninstall_package / push_file / install_adb_key
+	WritePathPatterns     []string `yaml:"write_path_patterns"`     // 输出重定向(> >> &>)允许写入的路径正则，为空时禁止输出重定向
+}
+
+// MonitorConfig 云手机后台健康监测配置
+type MonitorConfig struct {
+	Enabled          bool     `yaml:"enabled"`           // 是否启用后台监测
+	Interval         int      `yaml:"interval"`          // 探测周期（秒）
+	Jitter           int      `yaml:"jitter"`            // 每次探测的随机抖动上限（秒），避免所有设备同时探测
+	PingCount        int      `yaml:"ping_count"`        // 每次探测发送的ICMP数量
+	ProbeADB         bool     `yaml:"probe_adb"`         // 是否同时探测ADB端口和adbd握手
+	HistorySize      int      `yaml:"history_size"`      // 每台设备在内存中保留的历史样本数
+	Concurrency      int      `yaml:"concurrency"`       // 同时进行的探测数上限
+	Phones           []string `yaml:"phones"`            // 启动时注册的云手机IP
+	IdentityInterval int      `yaml:"identity_interval"` // 通过ADB检查SN/MAC是否变化的周期（秒），0表示不主动检查
+	EventBufferSize  int      `yaml:"event_buffer_size"` // 保留的最近事件数，用于事件订阅断线重连后续传
+}
+
+// HostConfig 宿主机资源状态采集配置
+type HostConfig struct {
+	SampleInterval int      `yaml:"sample_interval"` // CPU和网卡吞吐量的采样间隔（毫秒）
+	DiskPaths      []string `yaml:"disk_paths"`      // 统计使用率的宿主机挂载点
 }
 
 var (
@@ -86,4 +177,3 @@ func GetConfig() *Config {
 	defer configMutex.RUnlock()
 	return configInstance
 }
-
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/host"
+)
+
+const maxHostSampleInterval = 10 * time.Second // 单次请求允许的最长采样间隔
+
+// toHostStatusResponse 转换宿主机资源状态
+func toHostStatusResponse(status *host.Status) *server_operator.GetHostStatusResponse {
+	resp := &server_operator.GetHostStatusResponse{
+		Success:          true,
+		Message:          "获取宿主机状态成功",
+		Hostname:         status.Hostname,
+		UptimeSeconds:    int64(status.Uptime.Seconds()),
+		SampleIntervalMs: status.SampleInterval.Milliseconds(),
+		Cpu: &server_operator.HostCPUStatus{
+			Cores:         int32(status.CPU.Cores),
+			UsagePercent:  status.CPU.UsagePercent,
+			UserPercent:   status.CPU.UserPercent,
+			SystemPercent: status.CPU.SystemPercent,
+			IowaitPercent: status.CPU.IOWaitPercent,
+			StealPercent:  status.CPU.StealPercent,
+		},
+		Load: &server_operator.HostLoadStatus{
+			Load1:        status.Load.Load1,
+			Load5:        status.Load.Load5,
+			Load15:       status.Load.Load15,
+			RunningProcs: int32(status.Load.RunningProcs),
+			TotalProcs:   int32(status.Load.TotalProcs),
+		},
+		Memory: &server_operator.HostMemoryStatus{
+			Total:       status.Memory.Total,
+			Available:   status.Memory.Available,
+			Used:        status.Memory.Used,
+			Free:        status.Memory.Free,
+			Buffers:     status.Memory.Buffers,
+			Cached:      status.Memory.Cached,
+			SwapTotal:   status.Memory.SwapTotal,
+			SwapFree:    status.Memory.SwapFree,
+			UsedPercent: status.Memory.UsedPercent,
+		},
+		Warnings: status.Warnings,
+	}
+	for _, disk := range status.Disks {
+		resp.Disks = append(resp.Disks, &server_operator.HostDiskStatus{
+			Path:        disk.Path,
+			Total:       disk.Total,
+			Free:        disk.Free,
+			Available:   disk.Available,
+			Used:        disk.Used,
+			UsedPercent: disk.UsedPercent,
+			Inodes:      disk.Inodes,
+			InodesFree:  disk.InodesFree,
+		})
+	}
+	for _, iface := range status.Interfaces {
+		resp.Interfaces = append(resp.Interfaces, &server_operator.HostInterfaceStatus{
+			Name:      iface.Name,
+			RxBytes:   iface.RxBytes,
+			TxBytes:   iface.TxBytes,
+			RxPackets: iface.RxPackets,
+			TxPackets: iface.TxPackets,
+			RxErrors:  iface.RxErrors,
+			TxErrors:  iface.TxErrors,
+			RxDropped: iface.RxDropped,
+			TxDropped: iface.TxDropped,
+			RxMbps:    iface.RxMbps,
+			TxMbps:    iface.TxMbps,
+		})
+	}
+	if status.Conntrack != nil {
+		resp.Conntrack = &server_operator.HostConntrackStatus{
+			Count:        status.Conntrack.Count,
+			Max:          status.Conntrack.Max,
+			UsagePercent: status.Conntrack.UsagePercent,
+		}
+	}
+	if status.Nft != nil {
+		resp.Nft = &server_operator.HostNftStatus{
+			Tables: int32(status.Nft.Tables),
+			Chains: int32(status.Nft.Chains),
+			Sets:   int32(status.Nft.Sets),
+			Rules:  int32(status.Nft.Rules),
+			Bytes:  int32(status.Nft.Bytes),
+		}
+	}
+	return resp
+}
+
+// GetHostStatus 获取宿主机资源状态（CPU、负载、内存、磁盘、网卡吞吐量、连接跟踪表和nftables规则集规模）
+func (h *ServerOperatorHandler) GetHostStatus(ctx context.Context, req *server_operator.GetHostStatusRequest) (*server_operator.GetHostStatusResponse, error) {
+	sampleInterval := time.Duration(req.SampleIntervalMs) * time.Millisecond
+	if sampleInterval > maxHostSampleInterval {
+		sampleInterval = maxHostSampleInterval
+	}
+
+	status, err := h.hostStatus.Collect(ctx, sampleInterval)
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "获取宿主机状态失败: %v", err)
+		return &server_operator.GetHostStatusResponse{
+			Success: false,
+			Message: "获取宿主机状态失败: " + err.Error(),
+		}, nil
+	}
+	if len(status.Warnings) > 0 {
+		logger.WarnFWithContext(ctx, "获取宿主机状态部分数据失败: %v", status.Warnings)
+	}
+
+	logger.InfoFWithContext(ctx, "获取宿主机状态成功: CPU=%.1f%%, 负载=%.2f, 内存=%.1f%%", status.CPU.UsagePercent, status.Load.Load1, status.Memory.UsedPercent)
+	return toHostStatusResponse(status), nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toADBPublicKey 将ADB密钥转换为 proto 消息（只包含公钥）
+func toADBPublicKey(key *phone.ADBKey) *server_operator.ADBPublicKey {
+	return &server_operator.ADBPublicKey{
+		PublicKey:   key.PublicKey,
+		Fingerprint: key.Fingerprint,
+	}
+}
+
+// GetADBPublicKey 获取运维方当前ADB公钥及轮换中的历史公钥
+func (h *ServerOperatorHandler) GetADBPublicKey(ctx context.Context, req *server_operator.GetADBPublicKeyRequest) (*server_operator.GetADBPublicKeyResponse, error) {
+	if !h.adbKeys.Enabled() {
+		return nil, status.Error(codes.FailedPrecondition, "未配置ADB密钥")
+	}
+
+	resp := &server_operator.GetADBPublicKeyResponse{
+		Success: true,
+		Message: "获取ADB公钥成功",
+		Current: toADBPublicKey(h.adbKeys.Current()),
+	}
+	for _, key := range h.adbKeys.Previous() {
+		resp.Previous = append(resp.Previous, toADBPublicKey(key))
+	}
+	return resp, nil
+}
+
+// InstallPhoneADBKey 将运维方当前ADB公钥写入云手机 adb_keys，使设备长期信任该密钥
+// remove_previous 为 true 时同时移除历史公钥，完成密钥轮换
+func (h *ServerOperatorHandler) InstallPhoneADBKey(ctx context.Context, req *server_operator.InstallPhoneADBKeyRequest) (*server_operator.InstallPhoneADBKeyResponse, error) {
+	logger.InfoFWithContext(ctx, "写入云手机ADB公钥: IP=%s, 移除历史公钥=%v", req.IpAddress, req.RemovePrevious)
+
+	if !h.adbKeys.Enabled() {
+		return nil, status.Error(codes.FailedPrecondition, "未配置ADB密钥")
+	}
+	if err := h.checkOperationPolicy(ctx, req.IpAddress, phone.OperationInstallADBKey); err != nil {
+		return nil, err
+	}
+
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockExclusive)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	added, removed, err := h.adbKeys.InstallPublicKey(ctx, req.IpAddress, req.RemovePrevious, req.Timeout)
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "写入ADB公钥失败: IP=%s, 错误=%v", req.IpAddress, err)
+		return &server_operator.InstallPhoneADBKeyResponse{
+			Success: false,
+			Message: "写入ADB公钥失败: " + err.Error(),
+		}, nil
+	}
+
+	message := "ADB公钥已写入"
+	if !added {
+		message = "ADB公钥已存在"
+	}
+	logger.InfoFWithContext(ctx, "%s: IP=%s, 指纹=%s, 移除历史公钥=%d", message, req.IpAddress, h.adbKeys.Current().Fingerprint, removed)
+	return &server_operator.InstallPhoneADBKeyResponse{
+		Success:     true,
+		Message:     message,
+		Added:       added,
+		Removed:     int32(removed),
+		Fingerprint: h.adbKeys.Current().Fingerprint,
+	}, nil
+}
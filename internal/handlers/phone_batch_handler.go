@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultBatchMaxDevices     = 1000 // 默认单次批量操作设备数上限
+	defaultBatchMaxConcurrency = 32   // 默认批量操作并发上限
+	defaultBatchTimeout        = 300  // 默认批量操作整体超时时间（秒）
+)
+
+// runBatchOperation 对单台设备执行批量操作，复用对应的单设备RPC以保持行为一致
+func (h *ServerOperatorHandler) runBatchOperation(ctx context.Context, req *server_operator.BatchPhoneOperationRequest, ipAddress string) *server_operator.BatchPhoneResult {
+	start := time.Now()
+	result := &server_operator.BatchPhoneResult{IpAddress: ipAddress}
+
+	switch req.Operation {
+	case server_operator.BatchPhoneOperationType_BATCH_PHONE_PING:
+		resp, err := h.ExecutePhonePing(ctx, &server_operator.ExecutePhonePingRequest{
+			IpAddress: ipAddress,
+			Timeout:   req.Timeout,
+			Count:     req.PingCount,
+		})
+		if err != nil {
+			result.Message = err.Error()
+			break
+		}
+		result.Success, result.Message, result.TimedOut = resp.Success, resp.Message, resp.Timeout
+		result.Latency = resp.Latency
+
+	case server_operator.BatchPhoneOperationType_BATCH_PHONE_SERIAL_NUMBER:
+		resp, err := h.GetPhoneSerialNumber(ctx, &server_operator.GetPhoneSerialNumberRequest{
+			IpAddress: ipAddress,
+			Timeout:   req.Timeout,
+		})
+		if err != nil {
+			result.Message = err.Error()
+			break
+		}
+		result.Success, result.Message = resp.Success, resp.Message
+		result.SerialNumber = resp.SerialNumber
+
+	case server_operator.BatchPhoneOperationType_BATCH_PHONE_MAC_ADDRESS:
+		resp, err := h.GetPhoneMACAddress(ctx, &server_operator.GetPhoneMACAddressRequest{
+			IpAddress: ipAddress,
+			Timeout:   req.Timeout,
+		})
+		if err != nil {
+			result.Message = err.Error()
+			break
+		}
+		result.Success, result.Message = resp.Success, resp.Message
+		result.MacAddress = resp.MacAddress
+
+	case server_operator.BatchPhoneOperationType_BATCH_PHONE_COMMAND:
+		resp, err := h.ExecutePhoneCommand(ctx, &server_operator.ExecutePhoneCommandRequest{
+			IpAddress: ipAddress,
+			Command:   req.Command,
+			Timeout:   req.Timeout,
+		})
+		if err != nil {
+			result.Message = err.Error()
+			break
+		}
+		result.Success, result.Message = resp.Success, resp.Message
+		result.Stdout, result.Stderr, result.ExitCode = resp.Stdout, resp.Stderr, resp.ExitCode
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// checkBatchCommandPolicy 批量下发命令前整体检查一次命令策略，拒绝日志记录设备数和全部目标IP
+// 下发到每台设备时 ExecutePhoneCommand 仍会按设备IP再检查一次
+func (h *ServerOperatorHandler) checkBatchCommandPolicy(ctx context.Context, ipAddresses []string, command string) error {
+	caller := h.callerFromContext(ctx)
+	decision := h.commandPolicy.Evaluate(caller, command)
+	if decision.Allowed {
+		return nil
+	}
+
+	logger.WarnFWithContext(ctx, "批量命令被策略拒绝: 调用方=%s, 策略档案=%s, 设备数=%d, IP列表=%s, 命令=%s, 原因=%s",
+		caller, decision.Profile, len(ipAddresses), strings.Join(ipAddresses, ","), command, decision.Reason)
+	return status.Errorf(codes.PermissionDenied, "命令被策略拒绝: %s", decision.Reason)
+}
+
+// runBatchJob 执行单台设备的批量操作，panic 转为该设备的失败结果
+// 工作协程不在 gRPC 拦截器的 recover 范围内，未捕获的 panic 会导致整个进程退出
+func (h *ServerOperatorHandler) runBatchJob(ctx context.Context, req *server_operator.BatchPhoneOperationRequest, ipAddress string) (result *server_operator.BatchPhoneResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.ErrorFWithContext(ctx, "批量操作panic: IP=%s, 操作=%v, 错误=%v\n%s", ipAddress, req.Operation, r, debug.Stack())
+			result = &server_operator.BatchPhoneResult{
+				IpAddress: ipAddress,
+				Success:   false,
+				Message:   fmt.Sprintf("内部错误: %v", r),
+			}
+		}
+	}()
+	return h.runBatchOperation(ctx, req, ipAddress)
+}
+
+// BatchPhoneOperation 对一组云手机并发执行同一操作（Ping/SN/MAC/命令），每台设备完成即返回结果
+// 整体超时后未完成的设备以超时结果返回，最后一条消息 Done=true 携带汇总
+func (h *ServerOperatorHandler) BatchPhoneOperation(req *server_operator.BatchPhoneOperationRequest, stream server_operator.ServerOperatorService_BatchPhoneOperationServer) error {
+	ctx := stream.Context()
+
+	// 去重，保持请求顺序
+	seen := make(map[string]bool, len(req.IpAddresses))
+	ips := make([]string, 0, len(req.IpAddresses))
+	for _, ip := range req.IpAddresses {
+		if ip != "" && !seen[ip] {
+			seen[ip] = true
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return status.Error(codes.InvalidArgument, "IP列表为空")
+	}
+
+	maxDevices := h.cfg.Phone.BatchMaxDevices
+	if maxDevices <= 0 {
+		maxDevices = defaultBatchMaxDevices
+	}
+	if len(ips) > maxDevices {
+		return status.Errorf(codes.InvalidArgument, "设备数量超过上限: %d > %d", len(ips), maxDevices)
+	}
+
+	switch req.Operation {
+	case server_operator.BatchPhoneOperationType_BATCH_PHONE_PING,
+		server_operator.BatchPhoneOperationType_BATCH_PHONE_SERIAL_NUMBER,
+		server_operator.BatchPhoneOperationType_BATCH_PHONE_MAC_ADDRESS:
+	case server_operator.BatchPhoneOperationType_BATCH_PHONE_COMMAND:
+		if req.Command == "" {
+			return status.Error(codes.InvalidArgument, "命令为空")
+		}
+		// 先整体检查一次命令策略，被拒绝时不下发到任何设备
+		if err := h.checkBatchCommandPolicy(ctx, ips, req.Command); err != nil {
+			return err
+		}
+	default:
+		return status.Errorf(codes.InvalidArgument, "不支持的批量操作: %v", req.Operation)
+	}
+
+	concurrency := int(req.Concurrency)
+	maxConcurrency := h.cfg.Phone.BatchMaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultBatchMaxConcurrency
+	}
+	if concurrency <= 0 || concurrency > maxConcurrency {
+		concurrency = maxConcurrency
+	}
+	if concurrency > len(ips) {
+		concurrency = len(ips)
+	}
+
+	deadline := req.DeadlineSeconds
+	if deadline <= 0 {
+		deadline = int32(h.cfg.Phone.BatchTimeout)
+	}
+	if deadline <= 0 {
+		deadline = defaultBatchTimeout
+	}
+
+	logger.InfoFWithContext(ctx, "批量云手机操作: 操作=%v, 设备数=%d, 并发=%d, 整体超时=%ds", req.Operation, len(ips), concurrency, deadline)
+
+	batchCtx, batchCancel := context.WithTimeout(ctx, time.Duration(deadline)*time.Second)
+	defer batchCancel()
+
+	// 结果通道容量为设备数，超时后仍在执行的任务写入结果时不会阻塞
+	jobs := make(chan string)
+	results := make(chan *server_operator.BatchPhoneResult, len(ips))
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			for ip := range jobs {
+				results <- h.runBatchJob(batchCtx, req, ip)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, ip := range ips {
+			select {
+			case jobs <- ip:
+			case <-batchCtx.Done():
+				return
+			}
+		}
+	}()
+
+	var succeeded, failed, timedOut int32
+	finished := make(map[string]bool, len(ips))
+	send := func(result *server_operator.BatchPhoneResult) error {
+		finished[result.IpAddress] = true
+		switch {
+		case result.Success:
+			succeeded++
+		case result.TimedOut:
+			timedOut++
+		default:
+			failed++
+		}
+		return stream.Send(&server_operator.BatchPhoneOperationResponse{Result: result})
+	}
+
+collect:
+	for len(finished) < len(ips) {
+		select {
+		case result := <-results:
+			if err := send(result); err != nil {
+				return err
+			}
+		case <-batchCtx.Done():
+			break collect
+		}
+	}
+
+	if ctx.Err() != nil {
+		logger.WarnFWithContext(ctx, "批量操作被客户端取消: 已完成=%d/%d", len(finished), len(ips))
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	// 整体超时: 先取走已写入的结果，其余未完成的设备按超时返回
+	for drained := false; !drained && len(finished) < len(ips); {
+		select {
+		case result := <-results:
+			if err := send(result); err != nil {
+				return err
+			}
+		default:
+			drained = true
+		}
+	}
+	if len(finished) < len(ips) {
+		logger.WarnFWithContext(ctx, "批量操作整体超时: 已完成=%d/%d", len(finished), len(ips))
+		for _, ip := range ips {
+			if finished[ip] {
+				continue
+			}
+			if err := send(&server_operator.BatchPhoneResult{
+				IpAddress: ip,
+				Success:   false,
+				Message:   "批量操作整体超时，未完成",
+				TimedOut:  true,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	logger.InfoFWithContext(ctx, "批量操作完成: 总数=%d, 成功=%d, 失败=%d, 超时=%d", len(ips), succeeded, failed, timedOut)
+	return stream.Send(&server_operator.BatchPhoneOperationResponse{
+		Done:      true,
+		Total:     int32(len(ips)),
+		Succeeded: succeeded,
+		Failed:    failed,
+		TimedOut:  timedOut,
+		Success:   true,
+		Message:   "批量操作完成",
+	})
+}
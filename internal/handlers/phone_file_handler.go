@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultFileChunkSize = 256 * 1024             // 默认文件传输分块大小
+	fileProgressInterval = 500 * time.Millisecond // 进度上报最小间隔
+)
+
+// fileChunkSize 返回配置的文件传输分块大小
+func (h *ServerOperatorHandler) fileChunkSize() int {
+	if h.cfg.Phone.FileChunkSize > 0 {
+		return h.cfg.Phone.FileChunkSize
+	}
+	return defaultFileChunkSize
+}
+
+// uploadChunk 上传流中携带数据块的消息
+type uploadChunk interface {
+	GetData() []byte
+}
+
+// receiveUploadStream 在后台接收上传流，将首条消息及之后各消息的数据块按顺序转为 io.Reader
+// 客户端关闭发送端时读到 EOF，接收出错时读到该错误；处理结束后调用返回的 close 结束后台接收
+func receiveUploadStream[T uploadChunk](stream interface{ Recv() (T, error) }, first T) (io.Reader, func() error) {
+	pr, pw := io.Pipe()
+	go func() {
+		if data := first.GetData(); len(data) > 0 {
+			if _, err := pw.Write(data); err != nil {
+				return
+			}
+		}
+		for {
+			req, err := stream.Recv()
+			if err == io.EOF {
+				pw.Close()
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := pw.Write(req.GetData()); err != nil {
+				return
+			}
+		}
+	}()
+	return pr, pr.Close
+}
+
+// PushPhoneFile 推送文件到云手机
+// 首条消息携带IP、目标路径、权限、总大小和可选sha256，之后的消息只携带数据，客户端关闭发送端表示数据结束
+func (h *ServerOperatorHandler) PushPhoneFile(stream server_operator.ServerOperatorService_PushPhoneFileServer) error {
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if first.IpAddress == "" || first.RemotePath == "" {
+		return status.Error(codes.InvalidArgument, "首条消息必须携带IP地址和目标路径")
+	}
+
+	logger.InfoFWithContext(ctx, "推送文件到云手机: IP=%s, 路径=%s, 大小=%d, 权限=%o", first.IpAddress, first.RemotePath, first.TotalSize, first.Mode)
+
+	if err := h.checkOperationPolicy(ctx, first.IpAddress, phone.OperationPushFile); err != nil {
+		return err
+	}
+
+	release, err := h.lockDevice(ctx, first.IpAddress, phone.LockExclusive)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	maxSize := h.cfg.Phone.MaxPushFileSize
+	if maxSize > 0 && first.TotalSize > maxSize {
+		logger.WarnFWithContext(ctx, "推送文件超过大小限制: IP=%s, 大小=%d, 限制=%d", first.IpAddress, first.TotalSize, maxSize)
+		return stream.Send(&server_operator.PushPhoneFileResponse{
+			Type:    server_operator.FileTransferEventType_FILE_TRANSFER_DONE,
+			Success: false,
+			Message: "文件大小超过限制",
+		})
+	}
+
+	// 客户端数据流 -> 管道 -> ADB sync
+	pr, closeUpload := receiveUploadStream(stream, first)
+	defer closeUpload()
+
+	var lastReport time.Time
+	result, err := phone.PushFile(ctx, first.IpAddress, first.RemotePath, os.FileMode(first.Mode), pr, maxSize, first.Sha256, func(transferred int64) {
+		if time.Since(lastReport) < fileProgressInterval {
+			return
+		}
+		lastReport = time.Now()
+		_ = stream.Send(&server_operator.PushPhoneFileResponse{
+			Type:             server_operator.FileTransferEventType_FILE_TRANSFER_PROGRESS,
+			BytesTransferred: transferred,
+			TotalSize:        first.TotalSize,
+		})
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		logger.ErrorFWithContext(ctx, "推送文件失败: IP=%s, 路径=%s, 错误=%v", first.IpAddress, first.RemotePath, err)
+		resp := &server_operator.PushPhoneFileResponse{
+			Type:    server_operator.FileTransferEventType_FILE_TRANSFER_DONE,
+			Success: false,
+			Message: "推送文件失败: " + err.Error(),
+		}
+		if result != nil {
+			resp.BytesTransferred = result.Bytes
+			resp.Sha256 = result.SHA256
+		}
+		return stream.Send(resp)
+	}
+
+	logger.InfoFWithContext(ctx, "推送文件成功: IP=%s, 路径=%s, 大小=%d, sha256=%s, 已校验=%v",
+		first.IpAddress, first.RemotePath, result.Bytes, result.SHA256, result.Verified)
+	return stream.Send(&server_operator.PushPhoneFileResponse{
+		Type:             server_operator.FileTransferEventType_FILE_TRANSFER_DONE,
+		BytesTransferred: result.Bytes,
+		TotalSize:        result.Bytes,
+		Sha256:           result.SHA256,
+		Verified:         result.Verified,
+		Success:          true,
+		Message:          "推送文件成功",
+	})
+}
+
+// PullPhoneFile 从云手机拉取文件
+// 先发送 INFO（权限、大小、修改时间），再按块发送 DATA，最后发送 DONE（含sha256校验结果）
+func (h *ServerOperatorHandler) PullPhoneFile(req *server_operator.PullPhoneFileRequest, stream server_operator.ServerOperatorService_PullPhoneFileServer) error {
+	ctx := stream.Context()
+	logger.InfoFWithContext(ctx, "从云手机拉取文件: IP=%s, 路径=%s", req.IpAddress, req.RemotePath)
+
+	if req.IpAddress == "" || req.RemotePath == "" {
+		return status.Error(codes.InvalidArgument, "IP地址和文件路径不能为空")
+	}
+
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockShared)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var totalSize int64
+	writer := &chunkWriter{
+		size: h.fileChunkSize(),
+		send: func(data []byte, transferred int64) error {
+			return stream.Send(&server_operator.PullPhoneFileResponse{
+				Type:             server_operator.FileTransferEventType_FILE_TRANSFER_DATA,
+				Data:             data,
+				TotalSize:        totalSize,
+				BytesTransferred: transferred,
+			})
+		},
+	}
+
+	result, err := phone.PullFile(ctx, req.IpAddress, req.RemotePath, writer, h.cfg.Phone.MaxPullFileSize, func(stat *phone.FileStat) error {
+		totalSize = stat.Size
+		return stream.Send(&server_operator.PullPhoneFileResponse{
+			Type:      server_operator.FileTransferEventType_FILE_TRANSFER_INFO,
+			Mode:      uint32(stat.Mode),
+			TotalSize: stat.Size,
+			ModTime:   stat.ModTime.Unix(),
+		})
+	}, nil)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		logger.ErrorFWithContext(ctx, "拉取文件失败: IP=%s, 路径=%s, 错误=%v", req.IpAddress, req.RemotePath, err)
+		return stream.Send(&server_operator.PullPhoneFileResponse{
+			Type:             server_operator.FileTransferEventType_FILE_TRANSFER_DONE,
+			BytesTransferred: writer.transferred,
+			Success:          false,
+			Message:          "拉取文件失败: " + err.Error(),
+		})
+	}
+
+	logger.InfoFWithContext(ctx, "拉取文件成功: IP=%s, 路径=%s, 大小=%d, sha256=%s, 已校验=%v",
+		req.IpAddress, req.RemotePath, result.Bytes, result.SHA256, result.Verified)
+	return stream.Send(&server_operator.PullPhoneFileResponse{
+		Type:             server_operator.FileTransferEventType_FILE_TRANSFER_DONE,
+		Mode:             uint32(result.Mode),
+		TotalSize:        result.Bytes,
+		BytesTransferred: result.Bytes,
+		Sha256:           result.SHA256,
+		Verified:         result.Verified,
+		Success:          true,
+		Message:          "拉取文件成功",
+	})
+}
+
+// chunkWriter 将写入的数据按固定大小分块发送
+type chunkWriter struct {
+	size        int
+	buf         []byte
+	transferred int64
+	send        func(data []byte, transferred int64) error
+}
+
+// Write 缓冲数据，满一块即发送
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := w.size - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		if len(w.buf) >= w.size {
+			if err := w.Flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush 发送缓冲区中剩余的数据
+func (w *chunkWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	w.transferred += int64(len(w.buf))
+	if err := w.send(w.buf, w.transferred); err != nil {
+		return err
+	}
+	w.buf = nil
+	return nil
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// inputActionTypes proto 输入动作类型与服务层类型的对应关系
+var inputActionTypes = map[server_operator.PhoneInputActionType]phone.InputActionType{
+	server_operator.PhoneInputActionType_PHONE_INPUT_TAP:        phone.InputTap,
+	server_operator.PhoneInputActionType_PHONE_INPUT_SWIPE:      phone.InputSwipe,
+	server_operator.PhoneInputActionType_PHONE_INPUT_LONG_PRESS: phone.InputLongPress,
+	server_operator.PhoneInputActionType_PHONE_INPUT_KEY_EVENT:  phone.InputKeyEvent,
+	server_operator.PhoneInputActionType_PHONE_INPUT_TEXT:       phone.InputText,
+	server_operator.PhoneInputActionType_PHONE_INPUT_WAIT:       phone.InputWait,
+}
+
+// sendPhoneInput 执行输入动作序列并转换为统一响应
+func (h *ServerOperatorHandler) sendPhoneInput(ctx context.Context, ipAddress string, actions []phone.InputAction, timeout int32) (*server_operator.PhoneInputResponse, error) {
+	release, err := h.lockDevice(ctx, ipAddress, phone.LockExclusive)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result, err := phone.SendInput(ctx, ipAddress, actions, timeout)
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "云手机输入失败: IP=%s, 错误=%v", ipAddress, err)
+		resp := &server_operator.PhoneInputResponse{
+			Success:           false,
+			Message:           "输入失败: " + err.Error(),
+			FailedActionIndex: -1,
+		}
+		if result != nil {
+			resp.CompletedActions = int32(result.Completed)
+			resp.FailedActionIndex = int32(result.FailedIndex)
+		}
+		return resp, nil
+	}
+
+	logger.InfoFWithContext(ctx, "云手机输入成功: IP=%s, 动作数=%d", ipAddress, result.Completed)
+	return &server_operator.PhoneInputResponse{
+		Success:           true,
+		Message:           "输入成功",
+		CompletedActions:  int32(result.Completed),
+		FailedActionIndex: -1,
+	}, nil
+}
+
+// PhoneTap 点击云手机屏幕
+func (h *ServerOperatorHandler) PhoneTap(ctx context.Context, req *server_operator.PhoneTapRequest) (*server_operator.PhoneInputResponse, error) {
+	logger.InfoFWithContext(ctx, "云手机点击: IP=%s, 坐标=(%d, %d)", req.IpAddress, req.X, req.Y)
+	return h.sendPhoneInput(ctx, req.IpAddress, []phone.InputAction{{
+		Type: phone.InputTap,
+		X:    int(req.X),
+		Y:    int(req.Y),
+	}}, req.Timeout)
+}
+
+// PhoneSwipe 在云手机屏幕上滑动
+func (h *ServerOperatorHandler) PhoneSwipe(ctx context.Context, req *server_operator.PhoneSwipeRequest) (*server_operator.PhoneInputResponse, error) {
+	logger.InfoFWithContext(ctx, "云手机滑动: IP=%s, (%d, %d) -> (%d, %d), 时长=%dms",
+		req.IpAddress, req.X, req.Y, req.EndX, req.EndY, req.DurationMs)
+	return h.sendPhoneInput(ctx, req.IpAddress, []phone.InputAction{{
+		Type:       phone.InputSwipe,
+		X:          int(req.X),
+		Y:          int(req.Y),
+		EndX:       int(req.EndX),
+		EndY:       int(req.EndY),
+		DurationMs: int(req.DurationMs),
+	}}, req.Timeout)
+}
+
+// PhoneLongPress 长按云手机屏幕
+func (h *ServerOperatorHandler) PhoneLongPress(ctx context.Context, req *server_operator.PhoneLongPressRequest) (*server_operator.PhoneInputResponse, error) {
+	logger.InfoFWithContext(ctx, "云手机长按: IP=%s, 坐标=(%d, %d), 时长=%dms", req.IpAddress, req.X, req.Y, req.DurationMs)
+	return h.sendPhoneInput(ctx, req.IpAddress, []phone.InputAction{{
+		Type:       phone.InputLongPress,
+		X:          int(req.X),
+		Y:          int(req.Y),
+		DurationMs: int(req.DurationMs),
+	}}, req.Timeout)
+}
+
+// PhoneKeyEvent 向云手机发送按键
+func (h *ServerOperatorHandler) PhoneKeyEvent(ctx context.Context, req *server_operator.PhoneKeyEventRequest) (*server_operator.PhoneInputResponse, error) {
+	logger.InfoFWithContext(ctx, "云手机按键: IP=%s, 按键=%s, 长按=%v", req.IpAddress, req.KeyCode, req.LongPress)
+	return h.sendPhoneInput(ctx, req.IpAddress, []phone.InputAction{{
+		Type:      phone.InputKeyEvent,
+		KeyCode:   req.KeyCode,
+		LongPress: req.LongPress,
+	}}, req.Timeout)
+}
+
+// PhoneInputText 向云手机输入文本，支持空格、引号和Unicode
+// 含非ASCII字符或 % 的文本依赖 ADBKeyboard，设备需安装并选为当前输入法，否则返回失败
+func (h *ServerOperatorHandler) PhoneInputText(ctx context.Context, req *server_operator.PhoneInputTextRequest) (*server_operator.PhoneInputResponse, error) {
+	logger.InfoFWithContext(ctx, "云手机输入文本: IP=%s, 长度=%d", req.IpAddress, len([]rune(req.Text)))
+	return h.sendPhoneInput(ctx, req.IpAddress, []phone.InputAction{{
+		Type: phone.InputText,
+		Text: req.Text,
+	}}, req.Timeout)
+}
+
+// PhoneInputBatch 在一次ADB会话中按顺序执行一组输入动作，任一动作失败即停止
+func (h *ServerOperatorHandler) PhoneInputBatch(ctx context.Context, req *server_operator.PhoneInputBatchRequest) (*server_operator.PhoneInputResponse, error) {
+	logger.InfoFWithContext(ctx, "云手机批量输入: IP=%s, 动作数=%d", req.IpAddress, len(req.Actions))
+
+	actions := make([]phone.InputAction, 0, len(req.Actions))
+	for i, a := range req.Actions {
+		actionType, ok := inputActionTypes[a.Type]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "第%d个动作类型不支持: %v", i+1, a.Type)
+		}
+		actions = append(actions, phone.InputAction{
+			Type:       actionType,
+			X:          int(a.X),
+			Y:          int(a.Y),
+			EndX:       int(a.EndX),
+			EndY:       int(a.EndY),
+			DurationMs: int(a.DurationMs),
+			KeyCode:    a.KeyCode,
+			LongPress:  a.LongPress,
+			Text:       a.Text,
+		})
+	}
+	return h.sendPhoneInput(ctx, req.IpAddress, actions, req.Timeout)
+}
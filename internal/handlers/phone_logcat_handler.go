@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// logPriorityLetters 日志级别与 logcat 级别字母的对应关系
+var logPriorityLetters = map[server_operator.LogPriority]byte{
+	server_operator.LogPriority_LOG_PRIORITY_VERBOSE: 'V',
+	server_operator.LogPriority_LOG_PRIORITY_DEBUG:   'D',
+	server_operator.LogPriority_LOG_PRIORITY_INFO:    'I',
+	server_operator.LogPriority_LOG_PRIORITY_WARN:    'W',
+	server_operator.LogPriority_LOG_PRIORITY_ERROR:   'E',
+	server_operator.LogPriority_LOG_PRIORITY_FATAL:   'F',
+}
+
+// toLogPriority 将 logcat 级别字母转换为日志级别
+func toLogPriority(level byte) server_operator.LogPriority {
+	for priority, letter := range logPriorityLetters {
+		if letter == level {
+			return priority
+		}
+	}
+	return server_operator.LogPriority_LOG_PRIORITY_UNSPECIFIED
+}
+
+// toLogcatEntry 将解析后的日志转换为 proto 消息
+func toLogcatEntry(entry phone.LogcatEntry) *server_operator.LogcatEntry {
+	result := &server_operator.LogcatEntry{
+		Time:    entry.TimeText,
+		Pid:     entry.PID,
+		Tid:     entry.TID,
+		Level:   toLogPriority(entry.Level),
+		Tag:     entry.Tag,
+		Message: entry.Message,
+	}
+	if !entry.Time.IsZero() {
+		result.TimestampMs = entry.Time.UnixMilli()
+	}
+	return result
+}
+
+// StreamLogcat 流式读取云手机日志，每条消息携带一条解析后的日志，最后一条消息 Done=true
+func (h *ServerOperatorHandler) StreamLogcat(req *server_operator.StreamLogcatRequest, stream server_operator.ServerOperatorService_StreamLogcatServer) error {
+	ctx := stream.Context()
+	logger.InfoFWithContext(ctx, "读取云手机日志: IP=%s, 缓冲区=%v, Tag=%v, 级别=%v, 应用=%s, 起始=%d, 跟随=%v",
+		req.IpAddress, req.Buffers, req.Tags, req.MinPriority, req.PackageName, req.SinceTimestampMs, req.Follow)
+
+	opts := phone.LogcatOptions{
+		Buffers:     req.Buffers,
+		Tags:        req.Tags,
+		PackageName: req.PackageName,
+		Follow:      req.Follow,
+	}
+	if req.MinPriority != server_operator.LogPriority_LOG_PRIORITY_UNSPECIFIED {
+		letter, ok := logPriorityLetters[req.MinPriority]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "不支持的日志级别: %v", req.MinPriority)
+		}
+		opts.MinPriority = letter
+	}
+	if req.SinceTimestampMs > 0 {
+		opts.Since = time.UnixMilli(req.SinceTimestampMs)
+	}
+
+	count, err := phone.StreamLogcat(ctx, req.IpAddress, opts, req.Timeout, func(entry phone.LogcatEntry) error {
+		return stream.Send(&server_operator.StreamLogcatResponse{Entry: toLogcatEntry(entry)})
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			logger.InfoFWithContext(ctx, "日志读取被客户端取消: IP=%s, 已发送=%d", req.IpAddress, count)
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		logger.ErrorFWithContext(ctx, "读取日志失败: IP=%s, 错误=%v", req.IpAddress, err)
+		return stream.Send(&server_operator.StreamLogcatResponse{
+			Done:    true,
+			Success: false,
+			Message: "读取日志失败: " + err.Error(),
+		})
+	}
+
+	logger.InfoFWithContext(ctx, "读取日志完成: IP=%s, 条数=%d", req.IpAddress, count)
+	return stream.Send(&server_operator.StreamLogcatResponse{
+		Done:    true,
+		Success: true,
+		Message: "读取日志完成",
+	})
+}
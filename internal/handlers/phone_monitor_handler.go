@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/monitor"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// phoneEventTypes 服务层事件类型与 proto 枚举的对应关系
+var phoneEventTypes = map[monitor.EventType]server_operator.PhoneEventType{
+	monitor.EventHealthChanged:       server_operator.PhoneEventType_PHONE_EVENT_HEALTH_CHANGED,
+	monitor.EventSerialNumberChanged: server_operator.PhoneEventType_PHONE_EVENT_SERIAL_NUMBER_CHANGED,
+	monitor.EventMACAddressChanged:   server_operator.PhoneEventType_PHONE_EVENT_MAC_ADDRESS_CHANGED,
+	monitor.EventPortMappingAdded:    server_operator.PhoneEventType_PHONE_EVENT_PORT_MAPPING_ADDED,
+	monitor.EventPortMappingRemoved:  server_operator.PhoneEventType_PHONE_EVENT_PORT_MAPPING_REMOVED,
+}
+
+// checkMonitorEnabled 后台监测未启用时返回 FailedPrecondition
+func (h *ServerOperatorHandler) checkMonitorEnabled() error {
+	if h.monitor == nil || !h.monitor.Enabled() {
+		return status.Error(codes.FailedPrecondition, monitor.ErrMonitorDisabled.Error())
+	}
+	return nil
+}
+
+// unixMilli 返回毫秒时间戳，零值时间返回0
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// toHealthSample 转换监测样本
+func toHealthSample(sample monitor.Sample) *server_operator.PhoneHealthSample {
+	return &server_operator.PhoneHealthSample{
+		TimestampMs:  unixMilli(sample.Time),
+		Health:       networkHealths[sample.Health],
+		Reason:       sample.Reason,
+		LatencyMs:    durationMs(sample.Latency),
+		PacketLoss:   sample.LossPercent,
+		JitterMs:     durationMs(sample.Jitter),
+		AdbChecked:   sample.ADBChecked,
+		AdbOk:        sample.ADBOK,
+		AdbLatencyMs: durationMs(sample.ADBLatency),
+		FailedStage:  sample.FailedStage,
+	}
+}
+
+// toPhoneHealth 转换被监测设备状态
+func toPhoneHealth(state *monitor.PhoneState) *server_operator.PhoneHealth {
+	result := &server_operator.PhoneHealth{
+		IpAddress:           state.IPAddress,
+		Source:              state.Source,
+		RegisteredAtMs:      unixMilli(state.RegisteredAt),
+		HealthSinceMs:       unixMilli(state.HealthSince),
+		ConsecutiveFailures: int32(state.ConsecutiveFailures),
+		SerialNumber:        state.SerialNumber,
+		MacAddress:          state.MACAddress,
+		Trend: &server_operator.PhoneHealthTrend{
+			Samples:         int32(state.Trend.Samples),
+			AvgLatencyMs:    durationMs(state.Trend.AvgLatency),
+			MaxLatencyMs:    durationMs(state.Trend.MaxLatency),
+			AvgPacketLoss:   state.Trend.AvgLossPercent,
+			HealthyRatio:    state.Trend.HealthyRatio,
+			AdbAvailability: state.Trend.ADBAvailability,
+		},
+	}
+	if state.Current != nil {
+		result.Current = toHealthSample(*state.Current)
+	}
+	for _, sample := range state.History {
+		result.History = append(result.History, toHealthSample(sample))
+	}
+	return result
+}
+
+// RegisterMonitoredPhones 将云手机加入后台健康监测
+func (h *ServerOperatorHandler) RegisterMonitoredPhones(ctx context.Context, req *server_operator.RegisterMonitoredPhonesRequest) (*server_operator.RegisterMonitoredPhonesResponse, error) {
+	if err := h.checkMonitorEnabled(); err != nil {
+		return nil, err
+	}
+	if len(req.IpAddresses) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "IP列表为空")
+	}
+
+	logger.InfoFWithContext(ctx, "注册监测设备: 数量=%d", len(req.IpAddresses))
+
+	resp := &server_operator.RegisterMonitoredPhonesResponse{}
+	for _, ip := range req.IpAddresses {
+		added, err := h.monitor.Register(ip, monitor.SourceRPC)
+		if err != nil {
+			logger.WarnFWithContext(ctx, "注册监测设备失败: IP=%s, 错误=%v", ip, err)
+			resp.Failed = append(resp.Failed, ip)
+			continue
+		}
+		if added {
+			resp.Registered++
+		}
+	}
+
+	resp.Success = len(resp.Failed) == 0
+	if resp.Success {
+		resp.Message = "注册成功"
+	} else {
+		resp.Message = "部分设备注册失败"
+	}
+	logger.InfoFWithContext(ctx, "注册监测设备完成: 新增=%d, 失败=%d", resp.Registered, len(resp.Failed))
+	return resp, nil
+}
+
+// UnregisterMonitoredPhones 将云手机移出后台健康监测
+func (h *ServerOperatorHandler) UnregisterMonitoredPhones(ctx context.Context, req *server_operator.UnregisterMonitoredPhonesRequest) (*server_operator.UnregisterMonitoredPhonesResponse, error) {
+	if err := h.checkMonitorEnabled(); err != nil {
+		return nil, err
+	}
+	if len(req.IpAddresses) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "IP列表为空")
+	}
+
+	resp := &server_operator.UnregisterMonitoredPhonesResponse{Success: true, Message: "取消监测成功"}
+	for _, ip := range req.IpAddresses {
+		if h.monitor.Unregister(ip) {
+			resp.Unregistered++
+		}
+	}
+	logger.InfoFWithContext(ctx, "取消监测设备: 请求数=%d, 移除=%d", len(req.IpAddresses), resp.Unregistered)
+	return resp, nil
+}
+
+// GetPhoneHealth 查询被监测云手机的当前健康状态和近期趋势，IP列表为空时返回全部设备
+func (h *ServerOperatorHandler) GetPhoneHealth(ctx context.Context, req *server_operator.GetPhoneHealthRequest) (*server_operator.GetPhoneHealthResponse, error) {
+	if err := h.checkMonitorEnabled(); err != nil {
+		return nil, err
+	}
+
+	historyLimit := int(req.HistoryLimit)
+	resp := &server_operator.GetPhoneHealthResponse{Success: true, Message: "查询成功"}
+
+	if len(req.IpAddresses) == 0 {
+		for _, state := range h.monitor.States(historyLimit) {
+			resp.Phones = append(resp.Phones, toPhoneHealth(state))
+		}
+		return resp, nil
+	}
+
+	for _, ip := range req.IpAddresses {
+		state, ok := h.monitor.State(ip, historyLimit)
+		if !ok {
+			resp.NotFound = append(resp.NotFound, ip)
+			continue
+		}
+		resp.Phones = append(resp.Phones, toPhoneHealth(state))
+	}
+	if len(resp.NotFound) > 0 {
+		logger.WarnFWithContext(ctx, "查询健康状态: 未监测的设备=%v", resp.NotFound)
+	}
+	return resp, nil
+}
+
+// toPhoneEvent 转换设备事件
+func toPhoneEvent(event monitor.Event, epoch int64) *server_operator.PhoneEvent {
+	return &server_operator.PhoneEvent{
+		Sequence:    event.Sequence,
+		Epoch:       epoch,
+		TimestampMs: unixMilli(event.Time),
+		Type:        phoneEventTypes[event.Type],
+		IpAddress:   event.IPAddress,
+		OldHealth:   networkHealths[event.OldHealth],
+		NewHealth:   networkHealths[event.NewHealth],
+		Reason:      event.Reason,
+		OldValue:    event.OldValue,
+		NewValue:    event.NewValue,
+		MappedPort:  event.MappedPort,
+	}
+}
+
+// WatchPhoneEvents 订阅被监测云手机的状态变化事件（健康状态、SN/MAC变化、端口映射增删）
+// 第一条消息只携带事件纪元和订阅时的最新序号，FromSequence 为0的订阅在收到事件前以该序号作为续传起点；
+// 断线重连时传入最后收到的序号和纪元即可续传，
+// 序号已过期或服务重启导致纪元变化时返回 OutOfRange，调用方需通过 GetPhoneHealth 重新全量同步
+func (h *ServerOperatorHandler) WatchPhoneEvents(req *server_operator.WatchPhoneEventsRequest, stream server_operator.ServerOperatorService_WatchPhoneEventsServer) error {
+	ctx := stream.Context()
+	if err := h.checkMonitorEnabled(); err != nil {
+		return err
+	}
+
+	sub, backlog, err := h.monitor.Subscribe(req.FromSequence, req.Epoch)
+	if err != nil {
+		logger.WarnFWithContext(ctx, "订阅设备事件失败: 起始序号=%d, 纪元=%d, 错误=%v", req.FromSequence, req.Epoch, err)
+		if errors.Is(err, monitor.ErrEventsExpired) || errors.Is(err, monitor.ErrEventsReset) {
+			return status.Error(codes.OutOfRange, err.Error())
+		}
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	defer sub.Close()
+
+	epoch := h.monitor.EventEpoch()
+	logger.InfoFWithContext(ctx, "订阅设备事件: 起始序号=%d, 续传事件数=%d, 设备过滤=%d, 类型过滤=%d", req.FromSequence, len(backlog), len(req.IpAddresses), len(req.EventTypes))
+
+	ips := make(map[string]bool, len(req.IpAddresses))
+	for _, ip := range req.IpAddresses {
+		ips[ip] = true
+	}
+	types := make(map[server_operator.PhoneEventType]bool, len(req.EventTypes))
+	for _, t := range req.EventTypes {
+		types[t] = true
+	}
+
+	lastSequence := req.FromSequence
+	send := func(event monitor.Event) error {
+		lastSequence = event.Sequence
+		converted := toPhoneEvent(event, epoch)
+		if len(ips) > 0 && !ips[event.IPAddress] {
+			return nil
+		}
+		if len(types) > 0 && !types[converted.Type] {
+			return nil
+		}
+		return stream.Send(&server_operator.WatchPhoneEventsResponse{Event: converted, Epoch: epoch})
+	}
+
+	if err := stream.Send(&server_operator.WatchPhoneEventsResponse{Epoch: epoch, HeadSequence: sub.Head}); err != nil {
+		return err
+	}
+	for _, event := range backlog {
+		if err := send(event); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.InfoFWithContext(ctx, "设备事件订阅结束: 最后序号=%d", lastSequence)
+			return status.FromContextError(ctx.Err()).Err()
+		case event, ok := <-sub.C:
+			if !ok {
+				logger.WarnFWithContext(ctx, "设备事件订阅消费过慢被断开: 最后序号=%d", lastSequence)
+				return status.Errorf(codes.ResourceExhausted, "事件消费过慢，请从序号 %d 续传", lastSequence)
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
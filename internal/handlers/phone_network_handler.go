@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+)
+
+// toTraceHop 转换路由跟踪的一跳
+func toTraceHop(hop phone.TraceHop) *server_operator.TraceHop {
+	result := &server_operator.TraceHop{
+		Ttl:         int32(hop.TTL),
+		Addresses:   hop.Addresses,
+		Reached:     hop.Reached,
+		Unreachable: hop.Unreachable,
+		Sent:        int32(hop.Stats.Sent),
+		Received:    int32(hop.Stats.Received),
+		MinLatency:  durationMs(hop.Stats.Min),
+		AvgLatency:  durationMs(hop.Stats.Avg),
+		MaxLatency:  durationMs(hop.Stats.Max),
+		PacketLoss:  hop.Stats.LossPercent,
+	}
+	for _, probe := range hop.Stats.Probes {
+		result.Probes = append(result.Probes, &server_operator.PingProbe{
+			Seq:         int32(probe.Seq),
+			Received:    probe.Received,
+			RttMs:       durationMs(probe.RTT),
+			Unreachable: probe.Unreachable,
+		})
+	}
+	return result
+}
+
+// DiagnosePhoneNetwork 从宿主机网络命名空间诊断到云手机的网络路径（逐跳RTT和路径MTU）
+func (h *ServerOperatorHandler) DiagnosePhoneNetwork(ctx context.Context, req *server_operator.DiagnosePhoneNetworkRequest) (*server_operator.DiagnosePhoneNetworkResponse, error) {
+	logger.InfoFWithContext(ctx, "诊断云手机网络路径: IP=%s, 最大跳数=%d, 跳过路由跟踪=%v, 跳过MTU探测=%v", req.IpAddress, req.MaxHops, req.SkipTrace, req.SkipMtu)
+
+	diagnosis, err := phone.DiagnosePhoneNetwork(ctx, req.IpAddress, phone.DiagnoseOptions{
+		MaxHops:      int(req.MaxHops),
+		ProbesPerHop: int(req.ProbesPerHop),
+		ProbeTimeout: time.Duration(req.ProbeTimeoutMs) * time.Millisecond,
+		MaxMTU:       int(req.MaxMtu),
+		Timeout:      time.Duration(req.Timeout) * time.Second,
+		SkipTrace:    req.SkipTrace,
+		SkipMTU:      req.SkipMtu,
+	})
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "诊断网络路径失败: IP=%s, 错误=%v", req.IpAddress, err)
+		return &server_operator.DiagnosePhoneNetworkResponse{
+			Success: false,
+			Message: "诊断网络路径失败: " + err.Error(),
+		}, nil
+	}
+
+	resp := &server_operator.DiagnosePhoneNetworkResponse{
+		Success:    diagnosis.TraceError == "" && diagnosis.MTUError == "",
+		Address:    diagnosis.Address,
+		Namespace:  diagnosis.Namespace,
+		Reached:    diagnosis.Reached,
+		TraceError: diagnosis.TraceError,
+		PathMtu:    int32(diagnosis.PathMTU),
+		MtuError:   diagnosis.MTUError,
+	}
+	for _, hop := range diagnosis.Hops {
+		resp.Hops = append(resp.Hops, toTraceHop(hop))
+	}
+
+	if resp.Success {
+		resp.Message = "诊断完成"
+		logger.InfoFWithContext(ctx, "诊断网络路径完成: IP=%s, 命名空间=%s, 跳数=%d, 到达=%v, 路径MTU=%d", req.IpAddress, diagnosis.Namespace, len(diagnosis.Hops), diagnosis.Reached, diagnosis.PathMTU)
+	} else {
+		var failures []string
+		if diagnosis.TraceError != "" {
+			failures = append(failures, "路由跟踪: "+diagnosis.TraceError)
+		}
+		if diagnosis.MTUError != "" {
+			failures = append(failures, "MTU探测: "+diagnosis.MTUError)
+		}
+		resp.Message = "诊断部分失败: " + strings.Join(failures, "; ")
+		logger.WarnFWithContext(ctx, "诊断网络路径部分失败: IP=%s, %s", req.IpAddress, resp.Message)
+	}
+	return resp, nil
+}
+
+// toPhoneThroughput 转换单个方向的吞吐量结果，未测试的方向返回nil
+func toPhoneThroughput(throughput *phone.Throughput) *server_operator.PhoneThroughput {
+	if throughput == nil {
+		return nil
+	}
+	result := &server_operator.PhoneThroughput{
+		Success:    throughput.Err == nil,
+		Message:    "测试完成",
+		Bytes:      throughput.Bytes,
+		DurationMs: throughput.Duration.Milliseconds(),
+		Mbps:       throughput.Mbps,
+		Truncated:  throughput.Truncated,
+	}
+	if throughput.Err != nil {
+		result.Message = throughput.Err.Error()
+	} else if throughput.Truncated {
+		result.Message = "达到时长上限，提前结束"
+	}
+	return result
+}
+
+// MeasurePhoneBandwidth 通过ADB连接测试宿主机与云手机之间双向的TCP吞吐量（Mbps）
+func (h *ServerOperatorHandler) MeasurePhoneBandwidth(ctx context.Context, req *server_operator.MeasurePhoneBandwidthRequest) (*server_operator.MeasurePhoneBandwidthResponse, error) {
+	// 未配置上限时使用服务层默认值
+	maxSize := h.cfg.Phone.BandwidthTestMaxSize
+	if maxSize <= 0 {
+		maxSize = phone.DefaultBandwidthPayloadSize
+	}
+	maxDuration := time.Duration(h.cfg.Phone.BandwidthTestMaxDuration) * time.Second
+	if maxDuration <= 0 {
+		maxDuration = phone.DefaultBandwidthDuration
+	}
+
+	payloadSize := req.PayloadSize
+	if payloadSize <= 0 || payloadSize > maxSize {
+		payloadSize = maxSize
+	}
+	duration := time.Duration(req.DurationSeconds) * time.Second
+	if duration <= 0 || duration > maxDuration {
+		duration = maxDuration
+	}
+
+	opts := phone.BandwidthOptions{
+		PayloadSize:  payloadSize,
+		Duration:     duration,
+		SetupTimeout: time.Duration(h.cfg.Phone.ADBTimeout) * time.Second,
+	}
+	switch req.Direction {
+	case server_operator.BandwidthDirection_BANDWIDTH_HOST_TO_PHONE:
+		opts.HostToPhone = true
+	case server_operator.BandwidthDirection_BANDWIDTH_PHONE_TO_HOST:
+		opts.PhoneToHost = true
+	default:
+		opts.HostToPhone, opts.PhoneToHost = true, true
+	}
+
+	logger.InfoFWithContext(ctx, "测试云手机带宽: IP=%s, 方向=%v, 数据量上限=%d, 时长上限=%v", req.IpAddress, req.Direction, payloadSize, duration)
+
+	// 测试期间占满链路并读写设备临时文件，独占设备
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockExclusive)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result, err := phone.MeasureBandwidth(ctx, req.IpAddress, opts)
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "带宽测试失败: IP=%s, 错误=%v", req.IpAddress, err)
+		resp := &server_operator.MeasurePhoneBandwidthResponse{
+			Success: false,
+			Message: "带宽测试失败: " + err.Error(),
+		}
+		if result != nil {
+			resp.HostToPhone = toPhoneThroughput(result.HostToPhone)
+			resp.PhoneToHost = toPhoneThroughput(result.PhoneToHost)
+		}
+		return resp, nil
+	}
+
+	resp := &server_operator.MeasurePhoneBandwidthResponse{
+		Success:     true,
+		Message:     "带宽测试完成",
+		HostToPhone: toPhoneThroughput(result.HostToPhone),
+		PhoneToHost: toPhoneThroughput(result.PhoneToHost),
+	}
+	for _, throughput := range []*server_operator.PhoneThroughput{resp.HostToPhone, resp.PhoneToHost} {
+		if throughput != nil && !throughput.Success {
+			resp.Success = false
+			resp.Message = "带宽测试部分失败"
+		}
+	}
+
+	if resp.HostToPhone != nil {
+		logger.InfoFWithContext(ctx, "带宽测试 宿主机->云手机: IP=%s, %.2fMbps, %d字节, %dms, 成功=%v", req.IpAddress, resp.HostToPhone.Mbps, resp.HostToPhone.Bytes, resp.HostToPhone.DurationMs, resp.HostToPhone.Success)
+	}
+	if resp.PhoneToHost != nil {
+		logger.InfoFWithContext(ctx, "带宽测试 云手机->宿主机: IP=%s, %.2fMbps, %d字节, %dms, 成功=%v", req.IpAddress, resp.PhoneToHost.Mbps, resp.PhoneToHost.Bytes, resp.PhoneToHost.DurationMs, resp.PhoneToHost.Success)
+	}
+	return resp, nil
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// packageErrorCode 提取 pm 失败码，非 pm 错误返回空串
+func packageErrorCode(err error) string {
+	var pkgErr *phone.PackageError
+	if errors.As(err, &pkgErr) {
+		return pkgErr.Code
+	}
+	return ""
+}
+
+// InstallPhonePackage 上传并安装APK
+// 首条消息携带IP、安装选项、总大小和可选sha256，之后的消息只携带APK数据，客户端关闭发送端表示上传结束
+func (h *ServerOperatorHandler) InstallPhonePackage(stream server_operator.ServerOperatorService_InstallPhonePackageServer) error {
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if first.IpAddress == "" {
+		return status.Error(codes.InvalidArgument, "首条消息必须携带IP地址")
+	}
+
+	logger.InfoFWithContext(ctx, "安装云手机应用: IP=%s, 大小=%d, 覆盖=%v, 降级=%v, 授权=%v",
+		first.IpAddress, first.TotalSize, first.Replace, first.AllowDowngrade, first.GrantPermissions)
+
+	if err := h.checkOperationPolicy(ctx, first.IpAddress, phone.OperationInstallPackage); err != nil {
+		return err
+	}
+
+	release, err := h.lockDevice(ctx, first.IpAddress, phone.LockExclusive)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	maxSize := h.cfg.Phone.MaxPushFileSize
+	if maxSize > 0 && first.TotalSize > maxSize {
+		logger.WarnFWithContext(ctx, "APK超过大小限制: IP=%s, 大小=%d, 限制=%d", first.IpAddress, first.TotalSize, maxSize)
+		return stream.SendAndClose(&server_operator.InstallPhonePackageResponse{
+			Success: false,
+			Message: "APK大小超过限制",
+		})
+	}
+
+	pr, closeUpload := receiveUploadStream(stream, first)
+	defer closeUpload()
+
+	err = phone.InstallPackage(ctx, first.IpAddress, pr, maxSize, first.Sha256, phone.InstallOptions{
+		Replace:          first.Replace,
+		AllowDowngrade:   first.AllowDowngrade,
+		GrantPermissions: first.GrantPermissions,
+	}, int32(h.cfg.Phone.PackageInstallTimeout), nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		logger.ErrorFWithContext(ctx, "安装应用失败: IP=%s, 错误=%v", first.IpAddress, err)
+		return stream.SendAndClose(&server_operator.InstallPhonePackageResponse{
+			Success:   false,
+			Message:   "安装应用失败: " + err.Error(),
+			ErrorCode: packageErrorCode(err),
+		})
+	}
+
+	logger.InfoFWithContext(ctx, "安装应用成功: IP=%s", first.IpAddress)
+	return stream.SendAndClose(&server_operator.InstallPhonePackageResponse{
+		Success: true,
+		Message: "安装应用成功",
+	})
+}
+
+// UninstallPhonePackage 卸载云手机应用
+func (h *ServerOperatorHandler) UninstallPhonePackage(ctx context.Context, req *server_operator.UninstallPhonePackageRequest) (*server_operator.UninstallPhonePackageResponse, error) {
+	logger.InfoFWithContext(ctx, "卸载云手机应用: IP=%s, 包名=%s, 保留数据=%v", req.IpAddress, req.PackageName, req.KeepData)
+
+	if err := h.checkOperationPolicy(ctx, req.IpAddress, phone.OperationUninstallPackage); err != nil {
+		return nil, err
+	}
+
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockExclusive)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	err = phone.UninstallPackage(ctx, req.IpAddress, req.PackageName, req.KeepData)
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "卸载应用失败: IP=%s, 包名=%s, 错误=%v", req.IpAddress, req.PackageName, err)
+		return &server_operator.UninstallPhonePackageResponse{
+			Success:   false,
+			Message:   "卸载应用失败: " + err.Error(),
+			ErrorCode: packageErrorCode(err),
+		}, nil
+	}
+
+	logger.InfoFWithContext(ctx, "卸载应用成功: IP=%s, 包名=%s", req.IpAddress, req.PackageName)
+	return &server_operator.UninstallPhonePackageResponse{
+		Success: true,
+		Message: "卸载应用成功",
+	}, nil
+}
+
+// ListPhonePackages 列出云手机已安装应用
+func (h *ServerOperatorHandler) ListPhonePackages(ctx context.Context, req *server_operator.ListPhonePackagesRequest) (*server_operator.ListPhonePackagesResponse, error) {
+	logger.InfoFWithContext(ctx, "列出云手机应用: IP=%s, 包含系统应用=%v", req.IpAddress, req.IncludeSystem)
+
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockShared)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	packages, err := phone.ListPackages(ctx, req.IpAddress, req.IncludeSystem)
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "列出应用失败: IP=%s, 错误=%v", req.IpAddress, err)
+		return &server_operator.ListPhonePackagesResponse{
+			Success: false,
+			Message: "列出应用失败: " + err.Error(),
+		}, nil
+	}
+
+	infos := make([]*server_operator.PhonePackageInfo, 0, len(packages))
+	for _, pkg := range packages {
+		infos = append(infos, &server_operator.PhonePackageInfo{
+			PackageName: pkg.PackageName,
+			VersionCode: pkg.VersionCode,
+			VersionName: pkg.VersionName,
+			System:      pkg.System,
+			CodePath:    pkg.CodePath,
+		})
+	}
+
+	logger.InfoFWithContext(ctx, "列出应用成功: IP=%s, 数量=%d", req.IpAddress, len(infos))
+	return &server_operator.ListPhonePackagesResponse{
+		Success:  true,
+		Message:  "列出应用成功",
+		Packages: infos,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RebootPhone 重启云手机（正常/recovery/bootloader），不等待开机
+func (h *ServerOperatorHandler) RebootPhone(ctx context.Context, req *server_operator.RebootPhoneRequest) (*server_operator.RebootPhoneResponse, error) {
+	logger.InfoFWithContext(ctx, "重启云手机: IP=%s, 模式=%v", req.IpAddress, req.Mode)
+
+	var mode phone.RebootMode
+	switch req.Mode {
+	case server_operator.RebootMode_REBOOT_MODE_NORMAL:
+		mode = phone.RebootNormal
+	case server_operator.RebootMode_REBOOT_MODE_RECOVERY:
+		mode = phone.RebootRecovery
+	case server_operator.RebootMode_REBOOT_MODE_BOOTLOADER:
+		mode = phone.RebootBootloader
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "不支持的重启模式: %v", req.Mode)
+	}
+
+	if err := h.checkOperationPolicy(ctx, req.IpAddress, phone.OperationReboot); err != nil {
+		return nil, err
+	}
+
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockExclusive)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := phone.RebootPhone(ctx, req.IpAddress, mode); err != nil {
+		logger.ErrorFWithContext(ctx, "重启云手机失败: IP=%s, 错误=%v", req.IpAddress, err)
+		return &server_operator.RebootPhoneResponse{
+			Success: false,
+			Message: "重启失败: " + err.Error(),
+		}, nil
+	}
+
+	logger.InfoFWithContext(ctx, "重启命令已发送: IP=%s, 模式=%v", req.IpAddress, req.Mode)
+	return &server_operator.RebootPhoneResponse{
+		Success: true,
+		Message: "重启命令已发送",
+	}, nil
+}
+
+// RebootPhoneAndWait 正常重启云手机并等待开机完成，返回开机耗时或超时
+func (h *ServerOperatorHandler) RebootPhoneAndWait(ctx context.Context, req *server_operator.RebootPhoneAndWaitRequest) (*server_operator.RebootPhoneAndWaitResponse, error) {
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = int32(h.cfg.Phone.BootWaitTimeout)
+	}
+	logger.InfoFWithContext(ctx, "重启云手机并等待开机: IP=%s, 超时=%ds", req.IpAddress, timeout)
+
+	if err := h.checkOperationPolicy(ctx, req.IpAddress, phone.OperationReboot); err != nil {
+		return nil, err
+	}
+
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockExclusive)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	elapsed, err := phone.RebootAndWait(ctx, req.IpAddress, time.Duration(timeout)*time.Second)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		timedOut := errors.Is(err, phone.ErrBootTimeout)
+		if timedOut {
+			logger.WarnFWithContext(ctx, "等待云手机开机超时: IP=%s, 已等待=%v", req.IpAddress, elapsed)
+		} else {
+			logger.ErrorFWithContext(ctx, "重启云手机失败: IP=%s, 错误=%v", req.IpAddress, err)
+		}
+		return &server_operator.RebootPhoneAndWaitResponse{
+			Success:       false,
+			Message:       "重启失败: " + err.Error(),
+			TimedOut:      timedOut,
+			TimeToReadyMs: elapsed.Milliseconds(),
+		}, nil
+	}
+
+	logger.InfoFWithContext(ctx, "云手机开机完成: IP=%s, 耗时=%v", req.IpAddress, elapsed)
+	return &server_operator.RebootPhoneAndWaitResponse{
+		Success:       true,
+		Message:       "开机完成",
+		Ready:         true,
+		TimeToReadyMs: elapsed.Milliseconds(),
+	}, nil
+}
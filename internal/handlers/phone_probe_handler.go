@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+)
+
+// probeStatuses 服务层探测状态与 proto 枚举的对应关系
+var probeStatuses = map[phone.ProbeStatus]server_operator.ProbeStageStatus{
+	phone.ProbeSkipped: server_operator.ProbeStageStatus_PROBE_STAGE_SKIPPED,
+	phone.ProbeOK:      server_operator.ProbeStageStatus_PROBE_STAGE_OK,
+	phone.ProbeFailed:  server_operator.ProbeStageStatus_PROBE_STAGE_FAILED,
+}
+
+// ProbePhone 分阶段探测云手机（ICMP、ADB端口TCP连接、adbd握手、可选 echo ok），返回每个阶段的状态和耗时
+func (h *ServerOperatorHandler) ProbePhone(ctx context.Context, req *server_operator.ProbePhoneRequest) (*server_operator.ProbePhoneResponse, error) {
+	logger.InfoFWithContext(ctx, "分阶段探测云手机: IP=%s, ADB回显=%v", req.IpAddress, req.AdbEcho)
+
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = int32(h.cfg.Phone.ADBTimeout)
+	}
+
+	result := phone.ProbePhone(ctx, req.IpAddress, phone.ProbeOptions{
+		ADBEcho: req.AdbEcho,
+		Timeout: timeout,
+	})
+
+	resp := &server_operator.ProbePhoneResponse{
+		Success:     result.FailedStage == "",
+		FailedStage: result.FailedStage,
+	}
+	for _, stage := range result.Stages {
+		resp.Stages = append(resp.Stages, &server_operator.ProbeStage{
+			Name:      stage.Name,
+			Status:    probeStatuses[stage.Status],
+			LatencyMs: durationMs(stage.Latency),
+			Detail:    stage.Detail,
+		})
+	}
+
+	if resp.Success {
+		resp.Message = "探测成功"
+		logger.InfoFWithContext(ctx, "探测成功: IP=%s", req.IpAddress)
+	} else {
+		resp.Message = "探测失败: " + result.FailedStage
+		logger.WarnFWithContext(ctx, "探测失败: IP=%s, 失败阶段=%s", req.IpAddress, result.FailedStage)
+	}
+	return resp, nil
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+	"google.golang.org/grpc/status"
+)
+
+// CaptureScreenshot 截取云手机屏幕
+func (h *ServerOperatorHandler) CaptureScreenshot(ctx context.Context, req *server_operator.CaptureScreenshotRequest) (*server_operator.CaptureScreenshotResponse, error) {
+	logger.InfoFWithContext(ctx, "截取云手机屏幕: IP=%s, 格式=%v, 最大尺寸=%dx%d", req.IpAddress, req.Format, req.MaxWidth, req.MaxHeight)
+
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockShared)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	format := phone.ImageFormatPNG
+	if req.Format == server_operator.ImageFormat_IMAGE_FORMAT_JPEG {
+		format = phone.ImageFormatJPEG
+	}
+
+	shot, err := phone.CaptureScreenshot(ctx, req.IpAddress, phone.ScreenshotOptions{
+		Format:      format,
+		MaxWidth:    int(req.MaxWidth),
+		MaxHeight:   int(req.MaxHeight),
+		JPEGQuality: int(req.JpegQuality),
+	}, req.Timeout)
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "截图失败: IP=%s, 错误=%v", req.IpAddress, err)
+		return &server_operator.CaptureScreenshotResponse{
+			Success: false,
+			Message: "截图失败: " + err.Error(),
+		}, nil
+	}
+
+	logger.InfoFWithContext(ctx, "截图成功: IP=%s, 尺寸=%dx%d, 大小=%d", req.IpAddress, shot.Width, shot.Height, len(shot.Data))
+	return &server_operator.CaptureScreenshotResponse{
+		Success: true,
+		Message: "截图成功",
+		Image:   shot.Data,
+		Format:  req.Format,
+		Width:   int32(shot.Width),
+		Height:  int32(shot.Height),
+	}, nil
+}
+
+// RecordScreen 录制云手机屏幕，以原始H.264码流分块返回，最后一条消息 Done=true
+func (h *ServerOperatorHandler) RecordScreen(req *server_operator.RecordScreenRequest, stream server_operator.ServerOperatorService_RecordScreenServer) error {
+	ctx := stream.Context()
+	logger.InfoFWithContext(ctx, "录制云手机屏幕: IP=%s, 时长=%ds, 尺寸=%dx%d, 码率=%d",
+		req.IpAddress, req.DurationSeconds, req.Width, req.Height, req.BitRate)
+
+	maxDuration := time.Duration(h.cfg.Phone.ScreenRecordMaxDuration) * time.Second
+	recorded, truncated, err := phone.RecordScreen(ctx, req.IpAddress, phone.RecordOptions{
+		Duration: time.Duration(req.DurationSeconds) * time.Second,
+		Width:    int(req.Width),
+		Height:   int(req.Height),
+		BitRate:  int(req.BitRate),
+	}, maxDuration, h.cfg.Phone.ScreenRecordMaxSize, func(data []byte) error {
+		return stream.Send(&server_operator.RecordScreenResponse{Data: data})
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			logger.WarnFWithContext(ctx, "录屏被客户端取消: IP=%s, 已录制=%d", req.IpAddress, recorded)
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		logger.ErrorFWithContext(ctx, "录屏失败: IP=%s, 错误=%v", req.IpAddress, err)
+		return stream.Send(&server_operator.RecordScreenResponse{
+			BytesRecorded: recorded,
+			Done:          true,
+			Success:       false,
+			Message:       "录屏失败: " + err.Error(),
+		})
+	}
+
+	message := "录屏完成"
+	if truncated {
+		message = "录屏数据达到大小上限，已提前结束"
+		logger.WarnFWithContext(ctx, "录屏达到大小上限: IP=%s, 已录制=%d", req.IpAddress, recorded)
+	} else {
+		logger.InfoFWithContext(ctx, "录屏完成: IP=%s, 已录制=%d", req.IpAddress, recorded)
+	}
+
+	return stream.Send(&server_operator.RecordScreenResponse{
+		BytesRecorded: recorded,
+		Done:          true,
+		Truncated:     truncated,
+		Success:       true,
+		Message:       message,
+	})
+}
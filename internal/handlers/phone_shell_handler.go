@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/wumitech-com/mdcp_common/enum"
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_proto/api/server_operator"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PhoneShell 云手机交互式Shell会话
+// 首条消息必须为 START（携带IP和终端大小），之后客户端发送 STDIN/RESIZE，服务端回传 OUTPUT，会话结束时发送 EXIT
+func (h *ServerOperatorHandler) PhoneShell(stream server_operator.ServerOperatorService_PhoneShellServer) error {
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if first.Type != server_operator.PhoneShellRequestType_PHONE_SHELL_REQUEST_START || first.IpAddress == "" {
+		return status.Error(codes.InvalidArgument, "首条消息必须为START并携带IP地址")
+	}
+	if net.ParseIP(first.IpAddress) == nil {
+		return status.Errorf(codes.InvalidArgument, "IP地址无效: %q", first.IpAddress)
+	}
+
+	caller := h.callerFromContext(ctx)
+	if decision := h.commandPolicy.EvaluateInteractiveShell(caller); !decision.Allowed {
+		logger.WarnFWithContext(ctx, "交互式Shell被策略拒绝: 调用方=%s, 策略档案=%s, IP=%s, 原因=%s",
+			caller, decision.Profile, first.IpAddress, decision.Reason)
+		return status.Errorf(codes.PermissionDenied, "交互式Shell被策略拒绝: %s", decision.Reason)
+	}
+
+	traceID, _ := ctx.Value(enum.CtxKeyTrace).(string)
+	logger.InfoFWithContext(ctx, "打开云手机交互式Shell: IP=%s, 终端=%dx%d", first.IpAddress, first.Cols, first.Rows)
+
+	// 交互式会话时长由客户端决定，不加设备锁，避免长期阻塞其他操作
+	session, err := phone.StartShellSession(phone.ShellSessionOptions{
+		IPAddress:     first.IpAddress,
+		Rows:          uint16(first.Rows),
+		Cols:          uint16(first.Cols),
+		IdleTimeout:   time.Duration(h.cfg.Phone.ShellIdleTimeout) * time.Second,
+		TranscriptDir: h.cfg.Phone.ShellTranscriptDir,
+		TraceID:       traceID,
+	})
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "打开交互式Shell失败: IP=%s, 错误=%v", first.IpAddress, err)
+		return stream.Send(&server_operator.PhoneShellResponse{
+			Type:     server_operator.PhoneShellResponseType_PHONE_SHELL_RESPONSE_EXIT,
+			ExitCode: -1,
+			Success:  false,
+			Message:  "打开交互式Shell失败: " + err.Error(),
+		})
+	}
+	defer session.Close()
+
+	if err := stream.Send(&server_operator.PhoneShellResponse{
+		Type:    server_operator.PhoneShellResponseType_PHONE_SHELL_RESPONSE_STARTED,
+		Success: true,
+		Message: "交互式Shell已打开",
+	}); err != nil {
+		return err
+	}
+
+	// 输出转发：PTY -> 客户端，结束后才发送 EXIT，保证 Send 不并发
+	outputDone := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, readErr := session.Read(buf)
+			if n > 0 {
+				if err := stream.Send(&server_operator.PhoneShellResponse{
+					Type: server_operator.PhoneShellResponseType_PHONE_SHELL_RESPONSE_OUTPUT,
+					Data: append([]byte(nil), buf[:n]...),
+				}); err != nil {
+					outputDone <- err
+					return
+				}
+			}
+			if readErr != nil {
+				if errors.Is(readErr, io.EOF) {
+					readErr = nil
+				}
+				outputDone <- readErr
+				return
+			}
+		}
+	}()
+
+	// 输入转发：客户端 -> PTY，客户端关闭发送端或断开时结束会话
+	go func() {
+		defer session.Close()
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			switch req.Type {
+			case server_operator.PhoneShellRequestType_PHONE_SHELL_REQUEST_STDIN:
+				if _, err := session.Write(req.Data); err != nil {
+					logger.WarnFWithContext(ctx, "写入Shell输入失败: IP=%s, 错误=%v", first.IpAddress, err)
+					return
+				}
+			case server_operator.PhoneShellRequestType_PHONE_SHELL_REQUEST_RESIZE:
+				if err := session.Resize(uint16(req.Rows), uint16(req.Cols)); err != nil {
+					logger.WarnFWithContext(ctx, "调整终端大小失败: IP=%s, 错误=%v", first.IpAddress, err)
+				}
+			default:
+				logger.WarnFWithContext(ctx, "忽略未知的Shell消息类型: %v", req.Type)
+			}
+		}
+	}()
+
+	exitCode, waitErr := session.Wait()
+	if err := <-outputDone; err != nil {
+		logger.WarnFWithContext(ctx, "转发Shell输出失败: IP=%s, 错误=%v", first.IpAddress, err)
+	}
+
+	if ctx.Err() != nil {
+		logger.InfoFWithContext(ctx, "交互式Shell客户端已断开: IP=%s", first.IpAddress)
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	if waitErr != nil {
+		logger.WarnFWithContext(ctx, "交互式Shell结束: IP=%s, 原因=%v", first.IpAddress, waitErr)
+		return stream.Send(&server_operator.PhoneShellResponse{
+			Type:     server_operator.PhoneShellResponseType_PHONE_SHELL_RESPONSE_EXIT,
+			ExitCode: exitCode,
+			Success:  false,
+			Message:  "交互式Shell结束: " + waitErr.Error(),
+		})
+	}
+
+	logger.InfoFWithContext(ctx, "交互式Shell结束: IP=%s, ExitCode=%d", first.IpAddress, exitCode)
+	return stream.Send(&server_operator.PhoneShellResponse{
+		Type:     server_operator.PhoneShellResponseType_PHONE_SHELL_RESPONSE_EXIT,
+		ExitCode: exitCode,
+		Success:  true,
+		Message:  "交互式Shell已结束",
+	})
+}
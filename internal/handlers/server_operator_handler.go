@@ -2,12 +2,30 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
 
 	"github.com/wumitech-com/mdcp_common/logger"
 	"github.com/wumitech-com/mdcp_proto/api/server_operator"
 	"github.com/wumitech-com/mdcp_server_operator/internal/config"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/host"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/monitor"
 	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
 	"github.com/wumitech-com/mdcp_server_operator/internal/service/ubuntu"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	macSourceADB = "adb" // MAC地址来自设备网卡
+	macSourceARP = "arp" // MAC地址来自宿主机邻居表
+
+	defaultStreamCommandMaxTimeout = 600 // 默认流式命令最长执行时间（秒）
 )
 
 // ServerOperatorHandler 服务器操作处理器
@@ -15,10 +33,15 @@ type ServerOperatorHandler struct {
 	server_operator.UnimplementedServerOperatorServiceServer
 	cfg                 *config.Config
 	portMappingExecutor *ubuntu.PortMappingExecutor
+	commandPolicy       *phone.CommandPolicy
+	deviceLocks         *phone.DeviceLockManager
+	adbKeys             *phone.ADBKeyManager
+	monitor             *monitor.Monitor
+	hostStatus          *host.StatusCollector
 }
 
 // NewServerOperatorHandler 创建服务器操作处理器
-func NewServerOperatorHandler(cfg *config.Config) *ServerOperatorHandler {
+func NewServerOperatorHandler(cfg *config.Config, phoneMonitor *monitor.Monitor) (*ServerOperatorHandler, error) {
 	portMappingExecutor := ubuntu.NewPortMappingExecutor(
 		cfg.Ubuntu.ExternalIP,
 		cfg.Ubuntu.TargetPort,
@@ -26,9 +49,98 @@ func NewServerOperatorHandler(cfg *config.Config) *ServerOperatorHandler {
 		cfg.Ubuntu.ChainName,
 	)
 
+	commandPolicy, err := phone.NewCommandPolicy(cfg.Phone.CommandPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("加载命令策略失败: %v", err)
+	}
+	if commandPolicy.Enabled() && len(cfg.Phone.CommandPolicy.Callers) > 0 && !cfg.Server.GRPC.TLS.MutualTLSEnabled() {
+		return nil, fmt.Errorf("按调用方选择命令策略档案需要启用mTLS(server.grpc.tls.client_ca_file)")
+	}
+
+	adbKeys, err := phone.NewADBKeyManager(cfg.Phone.ADBKey)
+	if err != nil {
+		return nil, fmt.Errorf("加载ADB密钥失败: %v", err)
+	}
+	if err := adbKeys.Apply(context.Background()); err != nil {
+		return nil, fmt.Errorf("应用ADB密钥失败: %v", err)
+	}
+	if adbKeys.Enabled() {
+		logger.InfoF("已加载ADB密钥: 路径=%s, 指纹=%s, 历史密钥数=%d", adbKeys.Current().Path, adbKeys.Current().Fingerprint, len(adbKeys.Previous()))
+	}
+
 	return &ServerOperatorHandler{
 		cfg:                 cfg,
 		portMappingExecutor: portMappingExecutor,
+		commandPolicy:       commandPolicy,
+		deviceLocks:         phone.NewDeviceLockManager(cfg.Phone.DeviceLock),
+		adbKeys:             adbKeys,
+		monitor:             phoneMonitor,
+		hostStatus:          host.NewStatusCollector(cfg.Host),
+	}, nil
+}
+
+// callerFromContext 从已校验的客户端证书中获取调用方标识(CN)，用于选择命令策略档案
+// 未启用mTLS时没有可信的调用方身份，返回空串使用默认策略档案
+func (h *ServerOperatorHandler) callerFromContext(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+}
+
+// checkCommandPolicy 执行前检查命令策略，被拒绝时记录日志并返回 PermissionDenied
+func (h *ServerOperatorHandler) checkCommandPolicy(ctx context.Context, ipAddress, command string) error {
+	caller := h.callerFromContext(ctx)
+	decision := h.commandPolicy.Evaluate(caller, command)
+	if decision.Allowed {
+		return nil
+	}
+
+	logger.WarnFWithContext(ctx, "命令被策略拒绝: 调用方=%s, 策略档案=%s, IP=%s, 命令=%s, 原因=%s",
+		caller, decision.Profile, ipAddress, command, decision.Reason)
+	return status.Errorf(codes.PermissionDenied, "命令被策略拒绝: %s", decision.Reason)
+}
+
+// checkOperationPolicy 执行设备变更操作前检查策略，被拒绝时记录日志并返回 PermissionDenied
+func (h *ServerOperatorHandler) checkOperationPolicy(ctx context.Context, ipAddress, operation string) error {
+	caller := h.callerFromContext(ctx)
+	decision := h.commandPolicy.EvaluateOperation(caller, operation)
+	if decision.Allowed {
+		return nil
+	}
+
+	logger.WarnFWithContext(ctx, "操作被策略拒绝: 调用方=%s, 策略档案=%s, IP=%s, 操作=%s, 原因=%s",
+		caller, decision.Profile, ipAddress, operation, decision.Reason)
+	return status.Errorf(codes.PermissionDenied, "操作被策略拒绝: %s", decision.Reason)
+}
+
+// lockDevice 获取设备锁，排队已满返回 ResourceExhausted，等待超时返回 Aborted
+// 会修改设备状态的操作（含有执行时间上限的流式命令）使用独占锁；交互式Shell、日志、录屏等时长由客户端决定的会话和流不加锁，
+// 避免长期阻塞同一设备上的其他操作
+// 调用方应先校验参数再加锁；IP无效时直接返回 InvalidArgument，不以无效IP为键排队
+func (h *ServerOperatorHandler) lockDevice(ctx context.Context, ipAddress string, mode phone.LockMode) (func(), error) {
+	if net.ParseIP(ipAddress) == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "IP地址无效: %q", ipAddress)
+	}
+
+	release, err := h.deviceLocks.Acquire(ctx, ipAddress, mode)
+	if err == nil {
+		return release, nil
+	}
+
+	logger.WarnFWithContext(ctx, "获取设备锁失败: IP=%s, 独占=%v, 错误=%v", ipAddress, mode == phone.LockExclusive, err)
+	switch {
+	case errors.Is(err, phone.ErrDeviceBusy):
+		return nil, status.Errorf(codes.ResourceExhausted, "设备繁忙，排队已满: %s", ipAddress)
+	case errors.Is(err, phone.ErrLockWaitTimeout):
+		return nil, status.Errorf(codes.Aborted, "等待设备空闲超时: %s", ipAddress)
+	default:
+		return nil, status.FromContextError(err).Err()
 	}
 }
 
@@ -46,6 +158,7 @@ func (h *ServerOperatorHandler) EnablePortMapping(ctx context.Context, req *serv
 	}
 
 	logger.InfoFWithContext(ctx, "端口映射启用成功: %s:%d", req.InternalIp, req.MappedPort)
+	h.monitor.ObservePortMapping(req.InternalIp, req.MappedPort, true)
 	return &server_operator.EnablePortMappingResponse{
 		Success: true,
 		Message: "端口映射已启用",
@@ -56,7 +169,7 @@ func (h *ServerOperatorHandler) EnablePortMapping(ctx context.Context, req *serv
 func (h *ServerOperatorHandler) DisablePortMapping(ctx context.Context, req *server_operator.DisablePortMappingRequest) (*server_operator.DisablePortMappingResponse, error) {
 	logger.InfoFWithContext(ctx, "禁用端口映射: %d", req.MappedPort)
 
-	err := h.portMappingExecutor.DisablePortMapping(ctx, req.MappedPort)
+	internalIP, err := h.portMappingExecutor.DisablePortMapping(ctx, req.MappedPort)
 	if err != nil {
 		logger.ErrorFWithContext(ctx, "禁用端口映射失败: %v", err)
 		return &server_operator.DisablePortMappingResponse{
@@ -66,6 +179,9 @@ func (h *ServerOperatorHandler) DisablePortMapping(ctx context.Context, req *ser
 	}
 
 	logger.InfoFWithContext(ctx, "端口映射禁用成功: %d", req.MappedPort)
+	if internalIP != "" {
+		h.monitor.ObservePortMapping(internalIP, req.MappedPort, false)
+	}
 	return &server_operator.DisablePortMappingResponse{
 		Success: true,
 		Message: "端口映射已禁用",
@@ -106,40 +222,111 @@ func (h *ServerOperatorHandler) ExecutePhonePing(ctx context.Context, req *serve
 		count = 3
 	}
 
-	success, latency, err := phone.ExecutePing(req.IpAddress, timeout, count)
-	if err != nil {
-		logger.ErrorFWithContext(ctx, "执行Ping失败: IP=%s, 错误=%v", req.IpAddress, err)
-		return &server_operator.ExecutePhonePingResponse{
-			Success: false,
-			Message: "执行Ping失败: " + err.Error(),
-			Timeout: err.Error() == "ping超时",
-		}, nil
+	// Timeout 为单次探测超时，DeadlineSeconds 为整体超时（未指定时按次数和单次超时自动计算）
+	opts := phone.PingOptions{
+		Count:        count,
+		ProbeTimeout: time.Duration(timeout) * time.Second,
+		Timeout:      time.Duration(req.DeadlineSeconds) * time.Second,
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	if success {
-		logger.InfoFWithContext(ctx, "Ping成功: IP=%s, 延迟=%.2fms", req.IpAddress, latency)
-	} else {
-		logger.WarnFWithContext(ctx, "Ping失败: IP=%s", req.IpAddress)
+	stats, err := phone.ExecutePing(ctx, req.IpAddress, opts)
+
+	resp := &server_operator.ExecutePhonePingResponse{
+		Success: stats.Success(),
+		Timeout: errors.Is(err, phone.ErrPingDeadlineExceeded),
+		Outcome: pingOutcomes[stats.Outcome],
 	}
+	fillPingStats(resp, stats)
+	h.classifyPingHealth(resp, stats)
+
+	switch {
+	case err != nil:
+		resp.Message = "Ping失败: " + err.Error()
+		logger.WarnFWithContext(ctx, "Ping失败: IP=%s, 已完成=%d, 收到=%d, 错误=%v", req.IpAddress, stats.Sent, stats.Received, err)
+	case stats.Outcome == phone.PingOutcomePartialLoss:
+		resp.Message = fmt.Sprintf("Ping执行完成，丢包率%.1f%%", stats.LossPercent)
+	default:
+		resp.Message = "Ping执行完成"
+	}
+	if stats.Success() {
+		logger.InfoFWithContext(ctx, "Ping成功: IP=%s, 延迟=%.2fms, 丢包=%.1f%%, 抖动=%.2fms, 状态=%v(%s)",
+			req.IpAddress, durationMs(stats.Avg), stats.LossPercent, durationMs(stats.Jitter), resp.Health, resp.HealthReason)
+	}
+	return resp, nil
+}
 
-	return &server_operator.ExecutePhonePingResponse{
-		Success: success,
-		Message: "Ping执行完成",
-		Latency: latency,
-		Timeout: false,
-	}, nil
+// pingOutcomes 服务层Ping结果分类与 proto 枚举的对应关系
+var pingOutcomes = map[phone.PingOutcome]server_operator.PingOutcome{
+	phone.PingOutcomeOK:               server_operator.PingOutcome_PING_OUTCOME_OK,
+	phone.PingOutcomePartialLoss:      server_operator.PingOutcome_PING_OUTCOME_PARTIAL_LOSS,
+	phone.PingOutcomeTotalLoss:        server_operator.PingOutcome_PING_OUTCOME_TOTAL_LOSS,
+	phone.PingOutcomeHostUnreachable:  server_operator.PingOutcome_PING_OUTCOME_HOST_UNREACHABLE,
+	phone.PingOutcomeDNSFailure:       server_operator.PingOutcome_PING_OUTCOME_DNS_FAILURE,
+	phone.PingOutcomeDeadlineExceeded: server_operator.PingOutcome_PING_OUTCOME_DEADLINE_EXCEEDED,
+	phone.PingOutcomeError:            server_operator.PingOutcome_PING_OUTCOME_ERROR,
+}
+
+// networkHealths 服务层健康状态与 proto 枚举的对应关系
+var networkHealths = map[phone.NetworkHealth]server_operator.NetworkHealth{
+	phone.NetworkHealthUnknown:     server_operator.NetworkHealth_NETWORK_HEALTH_UNKNOWN,
+	phone.NetworkHealthHealthy:     server_operator.NetworkHealth_NETWORK_HEALTH_HEALTHY,
+	phone.NetworkHealthDegraded:    server_operator.NetworkHealth_NETWORK_HEALTH_DEGRADED,
+	phone.NetworkHealthUnreachable: server_operator.NetworkHealth_NETWORK_HEALTH_UNREACHABLE,
+}
+
+// classifyPingHealth 按配置的延迟和丢包阈值判定网络健康状态
+func (h *ServerOperatorHandler) classifyPingHealth(resp *server_operator.ExecutePhonePingResponse, stats *phone.PingStats) {
+	health, reason := phone.ClassifyPingHealth(stats, phone.NewHealthThresholds(h.cfg.Phone))
+	resp.Health = networkHealths[health]
+	resp.HealthReason = reason
+}
+
+// durationMs 将时长转换为毫秒
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// fillPingStats 将Ping统计写入响应，Latency 为平均延迟
+func fillPingStats(resp *server_operator.ExecutePhonePingResponse, stats *phone.PingStats) {
+	resp.Latency = durationMs(stats.Avg)
+	resp.MinLatency = durationMs(stats.Min)
+	resp.MaxLatency = durationMs(stats.Max)
+	resp.StddevLatency = durationMs(stats.StdDev)
+	resp.Jitter = durationMs(stats.Jitter)
+	resp.PacketLoss = stats.LossPercent
+	resp.Sent = int32(stats.Sent)
+	resp.Received = int32(stats.Received)
+	resp.Unreachable = int32(stats.Unreachable)
+	resp.UnreachableFrom = stats.UnreachableFrom
+	for _, probe := range stats.Probes {
+		resp.Probes = append(resp.Probes, &server_operator.PingProbe{
+			Seq:         int32(probe.Seq),
+			Received:    probe.Received,
+			RttMs:       durationMs(probe.RTT),
+			Unreachable: probe.Unreachable,
+		})
+	}
 }
 
 // GetPhoneSerialNumber 获取云手机SN码
 func (h *ServerOperatorHandler) GetPhoneSerialNumber(ctx context.Context, req *server_operator.GetPhoneSerialNumberRequest) (*server_operator.GetPhoneSerialNumberResponse, error) {
 	logger.InfoFWithContext(ctx, "获取云手机SN码: IP=%s", req.IpAddress)
 
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockShared)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	timeout := req.Timeout
 	if timeout <= 0 {
 		timeout = int32(h.cfg.Phone.ADBTimeout)
 	}
 
-	sn, err := phone.GetSerialNumberViaADB(req.IpAddress, timeout)
+	sn, err := phone.GetSerialNumberViaADB(ctx, req.IpAddress, timeout)
 	if err != nil {
 		logger.ErrorFWithContext(ctx, "获取SN码失败: IP=%s, 错误=%v", req.IpAddress, err)
 		return &server_operator.GetPhoneSerialNumberResponse{
@@ -150,6 +337,7 @@ func (h *ServerOperatorHandler) GetPhoneSerialNumber(ctx context.Context, req *s
 	}
 
 	logger.InfoFWithContext(ctx, "获取SN码成功: IP=%s, SN=%s", req.IpAddress, sn)
+	h.monitor.ObserveIdentity(req.IpAddress, sn, "")
 	return &server_operator.GetPhoneSerialNumberResponse{
 		Success:      true,
 		Message:      "获取SN码成功",
@@ -158,29 +346,242 @@ func (h *ServerOperatorHandler) GetPhoneSerialNumber(ctx context.Context, req *s
 }
 
 // GetPhoneMACAddress 获取云手机MAC地址
+// 可指定网卡名或要求返回持有该IP的网卡，响应中同时返回全部网卡
 func (h *ServerOperatorHandler) GetPhoneMACAddress(ctx context.Context, req *server_operator.GetPhoneMACAddressRequest) (*server_operator.GetPhoneMACAddressResponse, error) {
-	logger.InfoFWithContext(ctx, "获取云手机MAC地址: IP=%s", req.IpAddress)
+	logger.InfoFWithContext(ctx, "获取云手机MAC地址: IP=%s, 网卡=%s, 匹配IP=%v", req.IpAddress, req.Interface, req.MatchIp)
+
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockShared)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	timeout := req.Timeout
 	if timeout <= 0 {
 		timeout = int32(h.cfg.Phone.ADBTimeout)
 	}
 
-	mac, err := phone.GetMACAddressViaADB(req.IpAddress, timeout)
+	selected, interfaces, err := phone.GetMACAddressViaADB(ctx, req.IpAddress, phone.MACQuery{
+		Interface: req.Interface,
+		MatchIP:   req.MatchIp,
+	}, timeout)
+	if err != nil && interfaces == nil {
+		// ADB不可用（如adbd卡死）时回退到宿主机邻居表
+		neighbor, neighborErr := phone.LookupNeighborMAC(ctx, req.IpAddress, true, timeout)
+		if neighborErr == nil {
+			logger.WarnFWithContext(ctx, "ADB获取MAC失败，已从宿主机邻居表获取: IP=%s, MAC=%s, ADB错误=%v", req.IpAddress, neighbor.MACAddress, err)
+			return &server_operator.GetPhoneMACAddressResponse{
+				Success:       true,
+				Message:       "ADB获取MAC地址失败，已从宿主机邻居表获取",
+				MacAddress:    neighbor.MACAddress,
+				MacSource:     macSourceARP,
+				ArpMacAddress: neighbor.MACAddress,
+			}, nil
+		}
+		logger.WarnFWithContext(ctx, "宿主机邻居表查询MAC失败: IP=%s, 错误=%v", req.IpAddress, neighborErr)
+	}
 	if err != nil {
 		logger.ErrorFWithContext(ctx, "获取MAC地址失败: IP=%s, 错误=%v", req.IpAddress, err)
 		return &server_operator.GetPhoneMACAddressResponse{
 			Success:    false,
 			Message:    "获取MAC地址失败: " + err.Error(),
 			MacAddress: "",
+			Interfaces: toPhoneNetworkInterfaces(interfaces),
 		}, nil
 	}
 
-	logger.InfoFWithContext(ctx, "获取MAC地址成功: IP=%s, MAC=%s", req.IpAddress, mac)
-	return &server_operator.GetPhoneMACAddressResponse{
+	resp := &server_operator.GetPhoneMACAddressResponse{
+		Success:       true,
+		Message:       "获取MAC地址成功",
+		MacAddress:    selected.MACAddress,
+		InterfaceName: selected.Name,
+		Interfaces:    toPhoneNetworkInterfaces(interfaces),
+		MacSource:     macSourceADB,
+	}
+
+	// 与宿主机邻居表交叉校验，邻居表MAC不属于该设备时可能存在IP冲突
+	if neighbor, err := phone.LookupNeighborMAC(ctx, req.IpAddress, false, timeout); err == nil {
+		resp.ArpMacAddress = neighbor.MACAddress
+		if phone.DetectMACConflict(neighbor.MACAddress, interfaces) {
+			resp.MacConflict = true
+			logger.WarnFWithContext(ctx, "MAC地址不一致，可能存在IP冲突: IP=%s, ADB MAC=%s, 邻居表MAC=%s", req.IpAddress, selected.MACAddress, neighbor.MACAddress)
+		}
+	}
+
+	if req.Interface == "" && !req.MatchIp {
+		// 与后台监测使用相同的网卡选择规则时才上报，避免因选择不同网卡误报MAC变化
+		h.monitor.ObserveIdentity(req.IpAddress, "", selected.MACAddress)
+	}
+
+	logger.InfoFWithContext(ctx, "获取MAC地址成功: IP=%s, 网卡=%s, MAC=%s", req.IpAddress, selected.Name, selected.MACAddress)
+	return resp, nil
+}
+
+// GetPhoneNeighborMAC 从宿主机邻居表（ARP）获取云手机MAC地址，不依赖ADB
+// compare_adb 为 true 时同时通过ADB获取设备网卡并比对，标记可能的IP冲突
+func (h *ServerOperatorHandler) GetPhoneNeighborMAC(ctx context.Context, req *server_operator.GetPhoneNeighborMACRequest) (*server_operator.GetPhoneNeighborMACResponse, error) {
+	logger.InfoFWithContext(ctx, "查询宿主机邻居表MAC: IP=%s, 探测=%v, 比对ADB=%v", req.IpAddress, req.Probe, req.CompareAdb)
+
+	if net.ParseIP(req.IpAddress).To4() == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "IP地址无效: %q", req.IpAddress)
+	}
+
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = int32(h.cfg.Phone.ADBTimeout)
+	}
+
+	neighbor, err := phone.LookupNeighborMAC(ctx, req.IpAddress, req.Probe, timeout)
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "查询宿主机邻居表失败: IP=%s, 错误=%v", req.IpAddress, err)
+		return &server_operator.GetPhoneNeighborMACResponse{
+			Success: false,
+			Message: "查询宿主机邻居表失败: " + err.Error(),
+		}, nil
+	}
+
+	resp := &server_operator.GetPhoneNeighborMACResponse{
 		Success:    true,
-		Message:    "获取MAC地址成功",
-		MacAddress: mac,
+		Message:    "查询宿主机邻居表成功",
+		MacAddress: neighbor.MACAddress,
+		Device:     neighbor.Device,
+		State:      neighbor.State,
+	}
+
+	if req.CompareAdb {
+		selected, interfaces, err := phone.GetMACAddressViaADB(ctx, req.IpAddress, phone.MACQuery{}, timeout)
+		if err != nil {
+			logger.WarnFWithContext(ctx, "通过ADB获取MAC失败，无法比对: IP=%s, 错误=%v", req.IpAddress, err)
+			resp.Message = "查询宿主机邻居表成功，ADB比对失败: " + err.Error()
+		} else {
+			resp.AdbMacAddress = selected.MACAddress
+			resp.MacConflict = phone.DetectMACConflict(neighbor.MACAddress, interfaces)
+			if resp.MacConflict {
+				logger.WarnFWithContext(ctx, "MAC地址不一致，可能存在IP冲突: IP=%s, ADB MAC=%s, 邻居表MAC=%s", req.IpAddress, selected.MACAddress, neighbor.MACAddress)
+			}
+		}
+	}
+
+	logger.InfoFWithContext(ctx, "查询宿主机邻居表成功: IP=%s, MAC=%s, 状态=%s", req.IpAddress, neighbor.MACAddress, neighbor.State)
+	return resp, nil
+}
+
+// toPhoneNetworkInterfaces 转换网卡列表为响应结构
+func toPhoneNetworkInterfaces(interfaces []phone.InterfaceMAC) []*server_operator.PhoneNetworkInterface {
+	result := make([]*server_operator.PhoneNetworkInterface, 0, len(interfaces))
+	for _, iface := range interfaces {
+		result = append(result, &server_operator.PhoneNetworkInterface{
+			Name:        iface.Name,
+			MacAddress:  iface.MACAddress,
+			IpAddresses: iface.IPv4Addresses,
+		})
+	}
+	return result
+}
+
+// VerifyPhoneIdentity 校验IP当前对应的云手机是否为期望的设备（SN/MAC），用于发现DHCP重新分配导致的IP错配
+func (h *ServerOperatorHandler) VerifyPhoneIdentity(ctx context.Context, req *server_operator.VerifyPhoneIdentityRequest) (*server_operator.VerifyPhoneIdentityResponse, error) {
+	logger.InfoFWithContext(ctx, "校验云手机身份: IP=%s, 期望SN=%s, 期望MAC=%s", req.IpAddress, req.ExpectedSerialNumber, req.ExpectedMacAddress)
+
+	if req.ExpectedSerialNumber == "" && req.ExpectedMacAddress == "" {
+		return nil, status.Error(codes.InvalidArgument, "期望SN和期望MAC不能同时为空")
+	}
+
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockShared)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = int32(h.cfg.Phone.ADBTimeout)
+	}
+
+	result := phone.VerifyIdentity(ctx, req.IpAddress, req.ExpectedSerialNumber, req.ExpectedMacAddress, timeout)
+
+	checks := make([]*server_operator.IdentityFieldCheck, 0, len(result.Checks))
+	for _, check := range result.Checks {
+		checks = append(checks, &server_operator.IdentityFieldCheck{
+			Field:    check.Field,
+			Expected: check.Expected,
+			Actual:   check.Actual,
+			Checked:  check.Checked,
+			Matched:  check.Matched,
+			Error:    check.Error,
+		})
+	}
+
+	resp := &server_operator.VerifyPhoneIdentityResponse{
+		Success:          true,
+		Checks:           checks,
+		MismatchedFields: result.MismatchedFields,
+	}
+	switch result.Verdict {
+	case phone.IdentityMatch:
+		resp.Verdict = server_operator.IdentityVerdict_IDENTITY_VERDICT_MATCH
+		resp.Message = "设备身份一致"
+		logger.InfoFWithContext(ctx, "云手机身份一致: IP=%s", req.IpAddress)
+	case phone.IdentityMismatch:
+		resp.Verdict = server_operator.IdentityVerdict_IDENTITY_VERDICT_MISMATCH
+		resp.Message = "设备身份不一致: " + strings.Join(result.MismatchedFields, ",")
+		logger.WarnFWithContext(ctx, "云手机身份不一致: IP=%s, 不一致项=%v", req.IpAddress, result.MismatchedFields)
+	default:
+		resp.Verdict = server_operator.IdentityVerdict_IDENTITY_VERDICT_INCONCLUSIVE
+		resp.Message = "部分校验项无法执行，无法确认设备身份"
+		logger.WarnFWithContext(ctx, "云手机身份无法确认: IP=%s", req.IpAddress)
+	}
+
+	return resp, nil
+}
+
+// GetPhoneInfo 获取云手机软硬件信息（一次ADB连接完成采集）
+func (h *ServerOperatorHandler) GetPhoneInfo(ctx context.Context, req *server_operator.GetPhoneInfoRequest) (*server_operator.GetPhoneInfoResponse, error) {
+	logger.InfoFWithContext(ctx, "获取云手机信息: IP=%s", req.IpAddress)
+
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockShared)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = int32(h.cfg.Phone.ADBTimeout)
+	}
+
+	info, err := phone.GetDeviceInfo(ctx, req.IpAddress, timeout)
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "获取云手机信息失败: IP=%s, 错误=%v", req.IpAddress, err)
+		return &server_operator.GetPhoneInfoResponse{
+			Success: false,
+			Message: "获取云手机信息失败: " + err.Error(),
+		}, nil
+	}
+
+	logger.InfoFWithContext(ctx, "获取云手机信息成功: IP=%s, SN=%s, 型号=%s, Android=%s", req.IpAddress, info.SerialNumber, info.Model, info.AndroidVersion)
+	return &server_operator.GetPhoneInfoResponse{
+		Success: true,
+		Message: "获取云手机信息成功",
+		Info: &server_operator.PhoneInfo{
+			SerialNumber:          info.SerialNumber,
+			Model:                 info.Model,
+			Manufacturer:          info.Manufacturer,
+			Brand:                 info.Brand,
+			AndroidVersion:        info.AndroidVersion,
+			SdkVersion:            info.SDKVersion,
+			BuildFingerprint:      info.BuildFingerprint,
+			Imei:                  info.IMEI,
+			AndroidId:             info.AndroidID,
+			CpuAbi:                info.CPUABI,
+			CpuAbiList:            info.CPUABIList,
+			MemTotalBytes:         info.MemTotalBytes,
+			MemAvailableBytes:     info.MemAvailableBytes,
+			StorageTotalBytes:     info.StorageTotalBytes,
+			StorageAvailableBytes: info.StorageAvailableBytes,
+			UptimeSeconds:         info.UptimeSeconds,
+			Interfaces:            toPhoneNetworkInterfaces(info.Interfaces),
+		},
 	}, nil
 }
 
@@ -188,12 +589,26 @@ func (h *ServerOperatorHandler) GetPhoneMACAddress(ctx context.Context, req *ser
 func (h *ServerOperatorHandler) ExecutePhoneCommand(ctx context.Context, req *server_operator.ExecutePhoneCommandRequest) (*server_operator.ExecutePhoneCommandResponse, error) {
 	logger.InfoFWithContext(ctx, "执行云手机命令: IP=%s, 命令=%s", req.IpAddress, req.Command)
 
+	if req.IpAddress == "" || req.Command == "" {
+		return nil, status.Error(codes.InvalidArgument, "IP地址和命令不能为空")
+	}
+	// 先判定策略再排队加锁，被拒绝的命令不占用设备队列
+	if err := h.checkCommandPolicy(ctx, req.IpAddress, req.Command); err != nil {
+		return nil, err
+	}
+
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockExclusive)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	timeout := req.Timeout
 	if timeout <= 0 {
 		timeout = 30
 	}
 
-	stdout, stderr, exitCode, err := phone.ExecutePhoneCommand(req.IpAddress, req.Command, timeout)
+	stdout, stderr, exitCode, err := phone.ExecutePhoneCommand(ctx, req.IpAddress, req.Command, timeout)
 	if err != nil {
 		logger.ErrorFWithContext(ctx, "执行命令失败: IP=%s, 命令=%s, 错误=%v, ExitCode=%d", req.IpAddress, req.Command, err, exitCode)
 		return &server_operator.ExecutePhoneCommandResponse{
@@ -219,3 +634,70 @@ func (h *ServerOperatorHandler) ExecutePhoneCommand(ctx context.Context, req *se
 		ExitCode: exitCode,
 	}, nil
 }
+
+// StreamPhoneCommand 流式执行云手机命令，stdout/stderr 实时转发，最后发送退出码
+func (h *ServerOperatorHandler) StreamPhoneCommand(req *server_operator.StreamPhoneCommandRequest, stream server_operator.ServerOperatorService_StreamPhoneCommandServer) error {
+	ctx := stream.Context()
+	// 流式命令必须有执行时间上限，客户端未指定或超过上限时按上限执行
+	maxTimeout := int32(h.cfg.Phone.StreamCommandMaxTimeout)
+	if maxTimeout <= 0 {
+		maxTimeout = defaultStreamCommandMaxTimeout
+	}
+	timeout := req.Timeout
+	if timeout <= 0 || timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+	logger.InfoFWithContext(ctx, "流式执行云手机命令: IP=%s, 命令=%s, 超时=%ds", req.IpAddress, req.Command, timeout)
+
+	if req.IpAddress == "" || req.Command == "" {
+		return status.Error(codes.InvalidArgument, "IP地址和命令不能为空")
+	}
+	if err := h.checkCommandPolicy(ctx, req.IpAddress, req.Command); err != nil {
+		return err
+	}
+
+	// 流式命令可执行任意命令（如重启、安装），与 ExecutePhoneCommand 一样独占设备，最长占用到上述超时
+	release, err := h.lockDevice(ctx, req.IpAddress, phone.LockExclusive)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	exitCode, err := phone.StreamPhoneCommand(ctx, req.IpAddress, req.Command, timeout, func(output phone.OutputStream, data []byte) error {
+		outputType := server_operator.CommandOutputType_COMMAND_OUTPUT_STDOUT
+		if output == phone.OutputStderr {
+			outputType = server_operator.CommandOutputType_COMMAND_OUTPUT_STDERR
+		}
+		return stream.Send(&server_operator.StreamPhoneCommandResponse{
+			Type: outputType,
+			Data: data,
+		})
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			// 客户端已取消或断开，无法再发送结束消息
+			logger.WarnFWithContext(ctx, "流式命令被客户端取消: IP=%s, 命令=%s", req.IpAddress, req.Command)
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		logger.ErrorFWithContext(ctx, "流式执行命令失败: IP=%s, 命令=%s, 错误=%v", req.IpAddress, req.Command, err)
+		return stream.Send(&server_operator.StreamPhoneCommandResponse{
+			Type:     server_operator.CommandOutputType_COMMAND_OUTPUT_EXIT,
+			ExitCode: exitCode,
+			Success:  false,
+			Message:  "执行命令失败: " + err.Error(),
+		})
+	}
+
+	if exitCode != 0 {
+		logger.WarnFWithContext(ctx, "流式命令执行完成但退出码非0: IP=%s, 命令=%s, ExitCode=%d", req.IpAddress, req.Command, exitCode)
+	} else {
+		logger.InfoFWithContext(ctx, "流式命令执行成功: IP=%s, 命令=%s", req.IpAddress, req.Command)
+	}
+
+	return stream.Send(&server_operator.StreamPhoneCommandResponse{
+		Type:     server_operator.CommandOutputType_COMMAND_OUTPUT_EXIT,
+		ExitCode: exitCode,
+		Success:  true,
+		Message:  "命令执行完成",
+	})
+}
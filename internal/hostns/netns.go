@@ -0,0 +1,64 @@
+package hostns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// netnsPath 宿主机网络命名空间（容器以 --pid=host 运行，1号进程属于宿主机）
+const netnsPath = "/proc/1/ns/net"
+
+// ErrUnavailable 无法切换到宿主机网络命名空间
+var ErrUnavailable = errors.New("无法进入宿主机网络命名空间")
+
+// Run 通过 nsenter 在宿主机网络命名空间中执行命令，返回合并后的输出
+func Run(ctx context.Context, args ...string) (string, error) {
+	cmdArgs := append([]string{"-t", "1", "-n"}, args...)
+	output, err := exec.CommandContext(ctx, "nsenter", cmdArgs...).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("宿主机命令执行失败: %v, 输出: %s", err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// Do 在宿主机网络命名空间中执行 fn，fn 中创建的套接字在返回后仍属于宿主机命名空间
+// 切换命名空间失败时返回包装 ErrUnavailable 的错误，fn 不会被执行
+func Do(fn func() error) error {
+	// setns 只作用于当前线程，执行期间独占该线程
+	runtime.LockOSThread()
+
+	current, err := os.Open("/proc/thread-self/ns/net")
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer current.Close()
+
+	host, err := os.Open(netnsPath)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer host.Close()
+
+	if err := unix.Setns(int(host.Fd()), unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	fnErr := fn()
+
+	if err := unix.Setns(int(current.Fd()), unix.CLONE_NEWNET); err != nil {
+		// 无法切回时不解锁，goroutine 结束后该线程随之销毁，不会影响其他 goroutine
+		return fmt.Errorf("切回原网络命名空间失败: %v", err)
+	}
+	runtime.UnlockOSThread()
+	return fnErr
+}
@@ -23,3 +23,21 @@ func ChainUnaryServer(interceptors ...grpc.UnaryServerInterceptor) grpc.UnarySer
 		return chain(ctx, req)
 	}
 }
+
+// ChainStreamServer 链式调用多个StreamServerInterceptor
+func ChainStreamServer(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		// 从最后一个拦截器开始，向前构建调用链
+		chain := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor := interceptors[i]
+			next := chain
+			chain = func(interceptor grpc.StreamServerInterceptor, next grpc.StreamHandler) grpc.StreamHandler {
+				return func(srv interface{}, ss grpc.ServerStream) error {
+					return interceptor(srv, ss, info, next)
+				}
+			}(interceptor, next)
+		}
+		return chain(srv, ss)
+	}
+}
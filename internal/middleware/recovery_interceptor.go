@@ -29,3 +29,23 @@ func RecoveryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryS
 	}()
 	return handler(ctx, req)
 }
+
+// RecoveryStreamInterceptor 捕获流式 handler 中的 panic，记录包含 Trace 的错误日志并返回 Internal 错误
+func RecoveryStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ctx := ss.Context()
+			trace, _ := ctx.Value(enum.CtxKeyTrace).(string)
+			logger.ErrorFWithContext(ctx, "panic recovered - 方法: %s, Trace: %s, 错误: %v", info.FullMethod, trace, r)
+			var pcs [8]uintptr
+			n := runtime.Callers(3, pcs[:])
+			if n > 0 {
+				f := runtime.FuncForPC(pcs[0])
+				file, line := f.FileLine(pcs[0])
+				logger.ErrorFWithContext(ctx, "panic at %s:%d %s", file, line, f.Name())
+			}
+			err = status.Error(codes.Internal, "internal server error")
+		}
+	}()
+	return handler(srv, ss)
+}
@@ -173,3 +173,56 @@ func TraceInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServ
 
 	return resp, err
 }
+
+// tracedServerStream 包装 grpc.ServerStream，使 handler 拿到携带 trace_id 的 context
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context 返回携带 trace_id 的 context
+func (s *tracedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// TraceStreamInterceptor 是一个gRPC流式拦截器，确保每个流式请求都有trace_id
+func TraceStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := ss.Context()
+
+	// 获取或生成trace_id
+	traceID := getOrGenerateTraceID(ctx)
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		md = metadata.New(nil)
+	} else {
+		md = md.Copy()
+	}
+
+	// 确保metadata中有trace_id
+	existingTraceIDs := md.Get(TraceIDKey)
+	if len(existingTraceIDs) == 0 || !validateTraceID(existingTraceIDs[0]) {
+		md.Set(TraceIDKey, traceID)
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+
+	// 兼容公共logger：将 Trace 写入 enum.CtxKeyTrace，便于 LogWithContext 提取
+	ctx = context.WithValue(ctx, enum.CtxKeyTrace, traceID)
+
+	logger.InfoFWithContext(ctx, "流式请求开始 - 方法: %s, TraceID: %s", info.FullMethod, traceID)
+
+	// 流式响应的header随第一条消息发出，因此需在handler执行前设置
+	if err := ss.SetHeader(metadata.Pairs(TraceIDKey, traceID)); err != nil {
+		logger.WarnFWithContext(ctx, "设置响应头trace_id失败: %v", err)
+	}
+
+	err := handler(srv, &tracedServerStream{ServerStream: ss, ctx: ctx})
+
+	if err != nil {
+		logger.ErrorFWithContext(ctx, "流式请求失败 - 方法: %s, TraceID: %s, 错误: %v", info.FullMethod, traceID, err)
+	} else {
+		logger.InfoFWithContext(ctx, "流式请求成功 - 方法: %s, TraceID: %s", info.FullMethod, traceID)
+	}
+
+	return err
+}
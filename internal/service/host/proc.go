@@ -0,0 +1,239 @@
+package host
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cpuTimes /proc/stat 中 cpu 行的累计时间（单位: jiffies）
+type cpuTimes struct {
+	user, nice, system, idle, iowait, irq, softirq, steal uint64
+}
+
+// total 全部时间之和，guest 已计入 user，不重复累加
+func (t cpuTimes) total() uint64 {
+	return t.user + t.nice + t.system + t.idle + t.iowait + t.irq + t.softirq + t.steal
+}
+
+// sub 计算两次采样之间各项时间的增量
+// 内核的 iowait 计数可能回退，回退的项按0计，避免无符号数下溢
+func (t cpuTimes) sub(before cpuTimes) cpuTimes {
+	delta := func(after, before uint64) uint64 {
+		if after < before {
+			return 0
+		}
+		return after - before
+	}
+	return cpuTimes{
+		user:    delta(t.user, before.user),
+		nice:    delta(t.nice, before.nice),
+		system:  delta(t.system, before.system),
+		idle:    delta(t.idle, before.idle),
+		iowait:  delta(t.iowait, before.iowait),
+		irq:     delta(t.irq, before.irq),
+		softirq: delta(t.softirq, before.softirq),
+		steal:   delta(t.steal, before.steal),
+	}
+}
+
+// readCPUTimes 读取汇总的CPU时间和逻辑核数
+func readCPUTimes(path string) (cpuTimes, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return cpuTimes{}, 0, fmt.Errorf("读取%s失败: %v", path, err)
+	}
+	defer file.Close()
+
+	var (
+		times cpuTimes
+		found bool
+		cores int
+	)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if fields[0] != "cpu" {
+			cores++
+			continue
+		}
+		values := parseUints(fields[1:])
+		for len(values) < 8 {
+			values = append(values, 0)
+		}
+		times = cpuTimes{values[0], values[1], values[2], values[3], values[4], values[5], values[6], values[7]}
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return cpuTimes{}, 0, fmt.Errorf("读取%s失败: %v", path, err)
+	}
+	if !found {
+		return cpuTimes{}, 0, fmt.Errorf("%s中没有cpu汇总行", path)
+	}
+	return times, cores, nil
+}
+
+// cpuUsage 根据两次采样计算各项时间占比（%）
+func cpuUsage(before, after cpuTimes, cores int) CPUStatus {
+	status := CPUStatus{Cores: cores}
+	delta := after.sub(before)
+	total := float64(delta.total())
+	if total <= 0 {
+		return status
+	}
+	percent := func(v uint64) float64 {
+		return float64(v) * 100 / total
+	}
+	status.UserPercent = percent(delta.user + delta.nice)
+	status.SystemPercent = percent(delta.system + delta.irq + delta.softirq)
+	status.IOWaitPercent = percent(delta.iowait)
+	status.StealPercent = percent(delta.steal)
+	status.UsagePercent = 100 - percent(delta.idle+delta.iowait)
+	return status
+}
+
+// readLoadAvg 解析 /proc/loadavg
+func readLoadAvg(path string) (LoadStatus, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return LoadStatus{}, fmt.Errorf("读取%s失败: %v", path, err)
+	}
+	// 格式: 0.52 0.58 0.59 2/1234 56789
+	fields := strings.Fields(string(data))
+	if len(fields) < 4 {
+		return LoadStatus{}, fmt.Errorf("无法解析%s: %q", path, data)
+	}
+
+	var status LoadStatus
+	status.Load1, _ = strconv.ParseFloat(fields[0], 64)
+	status.Load5, _ = strconv.ParseFloat(fields[1], 64)
+	status.Load15, _ = strconv.ParseFloat(fields[2], 64)
+	if running, total, ok := strings.Cut(fields[3], "/"); ok {
+		status.RunningProcs, _ = strconv.Atoi(running)
+		status.TotalProcs, _ = strconv.Atoi(total)
+	}
+	return status, nil
+}
+
+// readMemInfo 解析 /proc/meminfo，单位转换为字节
+func readMemInfo(path string) (MemoryStatus, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return MemoryStatus{}, fmt.Errorf("读取%s失败: %v", path, err)
+	}
+	defer file.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 格式: MemTotal:       16318264 kB
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			value *= 1024
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return MemoryStatus{}, fmt.Errorf("读取%s失败: %v", path, err)
+	}
+
+	status := MemoryStatus{
+		Total:     values["MemTotal"],
+		Available: values["MemAvailable"],
+		Free:      values["MemFree"],
+		Buffers:   values["Buffers"],
+		Cached:    values["Cached"] + values["SReclaimable"],
+		SwapTotal: values["SwapTotal"],
+		SwapFree:  values["SwapFree"],
+	}
+	if status.Total == 0 {
+		return MemoryStatus{}, fmt.Errorf("%s中没有MemTotal", path)
+	}
+	if status.Available == 0 {
+		// 老内核没有MemAvailable，按空闲+缓存估算
+		status.Available = status.Free + status.Buffers + status.Cached
+	}
+	if status.Available < status.Total {
+		status.Used = status.Total - status.Available
+	}
+	status.UsedPercent = float64(status.Used) * 100 / float64(status.Total)
+	return status, nil
+}
+
+// netDevCounters 单个网卡的累计计数
+type netDevCounters struct {
+	rxBytes, rxPackets, rxErrors, rxDropped uint64
+	txBytes, txPackets, txErrors, txDropped uint64
+}
+
+// readNetDev 解析 /proc/<pid>/net/dev，返回网卡名顺序和计数
+func readNetDev(path string) ([]string, map[string]netDevCounters, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取%s失败: %v", path, err)
+	}
+	defer file.Close()
+
+	var names []string
+	counters := make(map[string]netDevCounters)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 前两行为表头，数据行格式: "  eth0: rx_bytes rx_packets rx_errs rx_drop ... tx_bytes tx_packets tx_errs tx_drop ..."
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		values := parseUints(strings.Fields(rest))
+		if len(values) < 16 {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		names = append(names, name)
+		counters[name] = netDevCounters{
+			rxBytes: values[0], rxPackets: values[1], rxErrors: values[2], rxDropped: values[3],
+			txBytes: values[8], txPackets: values[9], txErrors: values[10], txDropped: values[11],
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("读取%s失败: %v", path, err)
+	}
+	return names, counters, nil
+}
+
+// readUptime 读取 /proc/uptime 中的开机时长（秒）
+func readUptime(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("读取%s失败: %v", path, err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("无法解析%s: %q", path, data)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// parseUints 解析一组无符号整数，无法解析的记为0
+func parseUints(fields []string) []uint64 {
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		values[i], _ = strconv.ParseUint(field, 10, 64)
+	}
+	return values
+}
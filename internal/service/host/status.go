@@ -0,0 +1,320 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wumitech-com/mdcp_server_operator/internal/config"
+	"github.com/wumitech-com/mdcp_server_operator/internal/hostns"
+	"golang.org/x/sys/unix"
+)
+
+// 容器以 --privileged --pid=host 运行：/proc 下的CPU、负载、内存为宿主机全局数据，
+// 宿主机的文件系统和网络命名空间通过1号进程访问
+const (
+	procStatPath     = "/proc/stat"
+	procLoadAvgPath  = "/proc/loadavg"
+	procMemInfoPath  = "/proc/meminfo"
+	procUptimePath   = "/proc/uptime"
+	hostRootPath     = "/proc/1/root"
+	hostNetDevPath   = "/proc/1/net/dev"
+	hostHostnamePath = "/proc/1/root/etc/hostname"
+
+	defaultSampleInterval = time.Second
+	hostCommandTimeout    = 5 * time.Second
+)
+
+// CPUStatus CPU使用率，基于两次采样之间的差值（%）
+type CPUStatus struct {
+	Cores         int
+	UsagePercent  float64
+	UserPercent   float64
+	SystemPercent float64
+	IOWaitPercent float64
+	StealPercent  float64
+}
+
+// LoadStatus 系统负载
+type LoadStatus struct {
+	Load1        float64
+	Load5        float64
+	Load15       float64
+	RunningProcs int
+	TotalProcs   int
+}
+
+// MemoryStatus 内存使用情况（字节）
+type MemoryStatus struct {
+	Total       uint64
+	Available   uint64
+	Used        uint64 // Total - Available
+	Free        uint64
+	Buffers     uint64
+	Cached      uint64
+	SwapTotal   uint64
+	SwapFree    uint64
+	UsedPercent float64
+}
+
+// DiskStatus 宿主机文件系统使用情况（字节）
+type DiskStatus struct {
+	Path        string
+	Total       uint64
+	Free        uint64
+	Available   uint64 // 非root用户可用
+	Used        uint64
+	UsedPercent float64 // 与df一致: Used / (Used + Available)
+	Inodes      uint64
+	InodesFree  uint64
+}
+
+// InterfaceStatus 宿主机网卡累计计数和采样期间的吞吐量
+type InterfaceStatus struct {
+	Name      string
+	RxBytes   uint64
+	TxBytes   uint64
+	RxPackets uint64
+	TxPackets uint64
+	RxErrors  uint64
+	TxErrors  uint64
+	RxDropped uint64
+	TxDropped uint64
+	RxMbps    float64
+	TxMbps    float64
+}
+
+// ConntrackStatus 宿主机连接跟踪表使用情况
+type ConntrackStatus struct {
+	Count        uint64
+	Max          uint64
+	UsagePercent float64
+}
+
+// NftStatus 宿主机nftables规则集规模
+type NftStatus struct {
+	Tables int
+	Chains int
+	Sets   int
+	Rules  int
+	Bytes  int // 规则集文本大小
+}
+
+// Status 宿主机资源状态，可选数据项采集失败时为nil并记录到 Warnings
+type Status struct {
+	Hostname       string
+	Uptime         time.Duration
+	SampleInterval time.Duration
+	CPU            CPUStatus
+	Load           LoadStatus
+	Memory         MemoryStatus
+	Disks          []DiskStatus
+	Interfaces     []InterfaceStatus
+	Conntrack      *ConntrackStatus
+	Nft            *NftStatus
+	Warnings       []string
+}
+
+// StatusCollector 宿主机资源状态采集器
+type StatusCollector struct {
+	sampleInterval time.Duration
+	diskPaths      []string
+}
+
+// NewStatusCollector 创建宿主机资源状态采集器
+func NewStatusCollector(cfg config.HostConfig) *StatusCollector {
+	sampleInterval := time.Duration(cfg.SampleInterval) * time.Millisecond
+	if sampleInterval <= 0 {
+		sampleInterval = defaultSampleInterval
+	}
+	diskPaths := cfg.DiskPaths
+	if len(diskPaths) == 0 {
+		diskPaths = []string{"/"}
+	}
+	return &StatusCollector{sampleInterval: sampleInterval, diskPaths: diskPaths}
+}
+
+// Collect 采集宿主机资源状态，CPU使用率和网卡吞吐量在 sampleInterval 内采样两次计算
+// sampleInterval<=0 时使用配置的采样间隔；其余数据在采样窗口之前采集，避免 nsenter/nft 等自身开销计入CPU使用率
+func (c *StatusCollector) Collect(ctx context.Context, sampleInterval time.Duration) (*Status, error) {
+	if sampleInterval <= 0 {
+		sampleInterval = c.sampleInterval
+	}
+
+	status := &Status{}
+	warn := func(format string, args ...interface{}) {
+		status.Warnings = append(status.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	var err error
+	if status.Load, err = readLoadAvg(procLoadAvgPath); err != nil {
+		return nil, err
+	}
+	if status.Memory, err = readMemInfo(procMemInfoPath); err != nil {
+		return nil, err
+	}
+	if uptime, err := readUptime(procUptimePath); err == nil {
+		status.Uptime = time.Duration(uptime * float64(time.Second))
+	} else {
+		warn("读取开机时长失败: %v", err)
+	}
+	if data, err := os.ReadFile(hostHostnamePath); err == nil {
+		status.Hostname = strings.TrimSpace(string(data))
+	} else if hostname, err := os.Hostname(); err == nil {
+		status.Hostname = hostname
+	}
+	for _, path := range c.diskPaths {
+		disk, err := statDisk(path)
+		if err != nil {
+			warn("读取磁盘%s失败: %v", path, err)
+			continue
+		}
+		status.Disks = append(status.Disks, *disk)
+	}
+	if conntrack, err := readConntrack(ctx); err == nil {
+		status.Conntrack = conntrack
+	} else {
+		warn("读取连接跟踪表失败: %v", err)
+	}
+	if nft, err := readNftRuleset(ctx); err == nil {
+		status.Nft = nft
+	} else {
+		warn("读取nftables规则集失败: %v", err)
+	}
+
+	// 采样窗口内只等待，不做其他工作
+	cpuBefore, cores, err := readCPUTimes(procStatPath)
+	if err != nil {
+		return nil, err
+	}
+	_, netBefore, netErr := readNetDev(hostNetDevPath)
+	start := time.Now()
+
+	timer := time.NewTimer(sampleInterval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("采集已取消: %v", ctx.Err())
+	case <-timer.C:
+	}
+
+	cpuAfter, _, err := readCPUTimes(procStatPath)
+	if err != nil {
+		return nil, err
+	}
+	names, netAfter, err := readNetDev(hostNetDevPath)
+	elapsed := time.Since(start)
+	status.SampleInterval = elapsed
+	status.CPU = cpuUsage(cpuBefore, cpuAfter, cores)
+
+	switch {
+	case netErr != nil:
+		warn("读取网卡计数失败: %v", netErr)
+	case err != nil:
+		warn("读取网卡计数失败: %v", err)
+	default:
+		for _, name := range names {
+			after := netAfter[name]
+			iface := InterfaceStatus{
+				Name:      name,
+				RxBytes:   after.rxBytes,
+				TxBytes:   after.txBytes,
+				RxPackets: after.rxPackets,
+				TxPackets: after.txPackets,
+				RxErrors:  after.rxErrors,
+				TxErrors:  after.txErrors,
+				RxDropped: after.rxDropped,
+				TxDropped: after.txDropped,
+			}
+			// 采样期间新出现或计数回绕的网卡不计算吞吐量
+			if before, ok := netBefore[name]; ok && after.rxBytes >= before.rxBytes && after.txBytes >= before.txBytes {
+				iface.RxMbps = float64(after.rxBytes-before.rxBytes) * 8 / elapsed.Seconds() / 1e6
+				iface.TxMbps = float64(after.txBytes-before.txBytes) * 8 / elapsed.Seconds() / 1e6
+			}
+			status.Interfaces = append(status.Interfaces, iface)
+		}
+	}
+	return status, nil
+}
+
+// statDisk 统计宿主机上指定挂载点的文件系统使用情况
+func statDisk(path string) (*DiskStatus, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(filepath.Join(hostRootPath, path), &st); err != nil {
+		return nil, err
+	}
+
+	blockSize := uint64(st.Bsize)
+	disk := &DiskStatus{
+		Path:       path,
+		Total:      st.Blocks * blockSize,
+		Free:       st.Bfree * blockSize,
+		Available:  st.Bavail * blockSize,
+		Inodes:     st.Files,
+		InodesFree: st.Ffree,
+	}
+	disk.Used = disk.Total - disk.Free
+	if disk.Used+disk.Available > 0 {
+		disk.UsedPercent = float64(disk.Used) * 100 / float64(disk.Used+disk.Available)
+	}
+	return disk, nil
+}
+
+// readConntrack 读取宿主机网络命名空间的连接跟踪表计数（/proc/sys/net 按网络命名空间隔离）
+func readConntrack(ctx context.Context) (*ConntrackStatus, error) {
+	cmdCtx, cmdCancel := context.WithTimeout(ctx, hostCommandTimeout)
+	defer cmdCancel()
+
+	output, err := hostns.Run(cmdCtx, "cat", "/proc/sys/net/netfilter/nf_conntrack_count", "/proc/sys/net/netfilter/nf_conntrack_max")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("无法解析连接跟踪计数: %q", output)
+	}
+
+	status := &ConntrackStatus{}
+	if status.Count, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+		return nil, fmt.Errorf("无法解析连接跟踪计数: %q", fields[0])
+	}
+	if status.Max, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return nil, fmt.Errorf("无法解析连接跟踪上限: %q", fields[1])
+	}
+	if status.Max > 0 {
+		status.UsagePercent = float64(status.Count) * 100 / float64(status.Max)
+	}
+	return status, nil
+}
+
+// readNftRuleset 统计宿主机nftables规则集中的表、链、集合和规则数
+func readNftRuleset(ctx context.Context) (*NftStatus, error) {
+	cmdCtx, cmdCancel := context.WithTimeout(ctx, hostCommandTimeout)
+	defer cmdCancel()
+
+	output, err := hostns.Run(cmdCtx, "nft", "-a", "list", "ruleset")
+	if err != nil {
+		return nil, err
+	}
+
+	status := &NftStatus{Bytes: len(output)}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "table "):
+			status.Tables++
+		case strings.HasPrefix(line, "chain "):
+			status.Chains++
+		case strings.HasPrefix(line, "set "), strings.HasPrefix(line, "map "):
+			status.Sets++
+		case strings.Contains(line, "# handle "):
+			// -a 输出中规则行以 "# handle N" 结尾，表、链、集合已在上面计数
+			status.Rules++
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,153 @@
+package monitor
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+)
+
+const (
+	defaultEventBufferSize  = 1024 // 默认保留的最近事件数，用于断线重连后续传
+	subscriberChannelBuffer = 256  // 每个订阅者的事件缓冲数，写满视为消费过慢
+)
+
+var (
+	// ErrEventsExpired 续传起点之后的事件已被淘汰，调用方需重新全量同步
+	ErrEventsExpired = errors.New("续传起点之后的事件已过期")
+	// ErrEventsReset 事件序列已重置（服务重启），调用方需重新全量同步
+	ErrEventsReset = errors.New("事件序列已重置")
+)
+
+// EventType 设备事件类型
+type EventType int
+
+const (
+	EventHealthChanged       EventType = iota + 1 // 健康状态变化
+	EventSerialNumberChanged                      // SN码变化
+	EventMACAddressChanged                        // MAC地址变化
+	EventPortMappingAdded                         // 新增指向设备的端口映射
+	EventPortMappingRemoved                       // 删除指向设备的端口映射
+)
+
+// Event 被监测设备的状态变化事件
+type Event struct {
+	Sequence   uint64 // 进程内单调递增的序号，从1开始
+	Time       time.Time
+	Type       EventType
+	IPAddress  string
+	OldHealth  phone.NetworkHealth // EventHealthChanged
+	NewHealth  phone.NetworkHealth // EventHealthChanged
+	Reason     string
+	OldValue   string // EventSerialNumberChanged / EventMACAddressChanged
+	NewValue   string // EventSerialNumberChanged / EventMACAddressChanged
+	MappedPort int32  // EventPortMappingAdded / EventPortMappingRemoved
+}
+
+// Subscription 事件订阅，C 被关闭且 Lagged() 为 true 时表示消费过慢被断开
+type Subscription struct {
+	C    <-chan Event
+	Head uint64 // 订阅时最新事件的序号，C 中的实时事件序号均大于它
+
+	ch     chan Event
+	log    *eventLog
+	lagged bool
+}
+
+// Lagged 订阅是否因消费过慢被断开
+func (s *Subscription) Lagged() bool {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+	return s.lagged
+}
+
+// Close 取消订阅
+func (s *Subscription) Close() {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+	if _, ok := s.log.subscribers[s]; ok {
+		delete(s.log.subscribers, s)
+		close(s.ch)
+	}
+}
+
+// eventLog 保留最近事件并向订阅者分发
+type eventLog struct {
+	mu          sync.Mutex
+	epoch       int64 // 事件序列的纪元（创建时间毫秒），服务重启后变化
+	size        int
+	sequence    uint64
+	events      []Event
+	subscribers map[*Subscription]struct{}
+}
+
+// newEventLog 创建保留 size 个最近事件的事件日志
+func newEventLog(size int) *eventLog {
+	if size <= 0 {
+		size = defaultEventBufferSize
+	}
+	return &eventLog{
+		epoch:       time.Now().UnixMilli(),
+		size:        size,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// publish 分配序号并分发事件，订阅者缓冲写满时断开该订阅者
+func (l *eventLog) publish(event Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sequence++
+	event.Sequence = l.sequence
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	l.events = append(l.events, event)
+	if len(l.events) > l.size {
+		l.events = append(l.events[:0:0], l.events[len(l.events)-l.size:]...)
+	}
+
+	for sub := range l.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			sub.lagged = true
+			delete(l.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// subscribe 订阅事件，返回序号大于 fromSequence 的已保留事件和后续实时事件
+// fromSequence 为0时只订阅实时事件；epoch 非0且与当前纪元不一致时返回 ErrEventsReset
+func (l *eventLog) subscribe(fromSequence uint64, epoch int64) (*Subscription, []Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if epoch != 0 && epoch != l.epoch {
+		return nil, nil, ErrEventsReset
+	}
+
+	var backlog []Event
+	if fromSequence > 0 {
+		if fromSequence > l.sequence {
+			return nil, nil, ErrEventsReset
+		}
+		if len(l.events) > 0 && fromSequence+1 < l.events[0].Sequence {
+			return nil, nil, ErrEventsExpired
+		}
+		for _, event := range l.events {
+			if event.Sequence > fromSequence {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	ch := make(chan Event, subscriberChannelBuffer)
+	sub := &Subscription{C: ch, Head: l.sequence, ch: ch, log: l}
+	l.subscribers[sub] = struct{}{}
+	return sub, backlog, nil
+}
@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"time"
+
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+)
+
+// Sample 一次探测的结果
+type Sample struct {
+	Time        time.Time
+	Health      phone.NetworkHealth
+	Reason      string
+	Latency     time.Duration // ICMP平均延迟，Received 为0时无意义
+	LossPercent float64       // Sent 为0时无意义
+	Sent        int           // 已得出结果的ICMP探测数，为0表示Ping在发出探测前失败（如套接字错误）
+	Received    int           // 收到应答的ICMP探测数
+	Jitter      time.Duration
+	ADBChecked  bool // 是否探测了ADB
+	ADBOK       bool
+	ADBLatency  time.Duration // adbd握手耗时
+	FailedStage string        // ADB探测中第一个失败的阶段
+}
+
+// Trend 历史样本的汇总
+type Trend struct {
+	Samples         int
+	AvgLatency      time.Duration // 只统计收到应答的样本
+	MaxLatency      time.Duration
+	AvgLossPercent  float64 // 只统计发出过探测的样本
+	HealthyRatio    float64 // 健康样本占比
+	ADBAvailability float64 // ADB探测成功占比，未探测ADB时为0
+}
+
+// history 定长的样本环形缓冲区
+type history struct {
+	samples []Sample
+	next    int
+	full    bool
+}
+
+// newHistory 创建容量为 size 的历史缓冲区
+func newHistory(size int) *history {
+	return &history{samples: make([]Sample, size)}
+}
+
+// add 追加样本，超过容量时覆盖最旧的样本
+func (h *history) add(sample Sample) {
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list 按时间顺序返回最近 limit 个样本，limit<=0 返回全部
+func (h *history) list(limit int) []Sample {
+	var ordered []Sample
+	if h.full {
+		ordered = append(ordered, h.samples[h.next:]...)
+	}
+	ordered = append(ordered, h.samples[:h.next]...)
+	if limit > 0 && len(ordered) > limit {
+		ordered = ordered[len(ordered)-limit:]
+	}
+	return ordered
+}
+
+// trend 汇总全部历史样本
+func (h *history) trend() Trend {
+	samples := h.list(0)
+	trend := Trend{Samples: len(samples)}
+	if len(samples) == 0 {
+		return trend
+	}
+
+	var (
+		latencySum          time.Duration
+		latencySamples      int
+		lossSum             float64
+		lossSamples         int
+		healthy, adbChecked int
+		adbOK               int
+	)
+	for _, sample := range samples {
+		if sample.Health == phone.NetworkHealthHealthy {
+			healthy++
+		}
+		// Ping在发出探测前失败的样本没有丢包率和延迟，计入会把平均值拉向0
+		if sample.Sent > 0 {
+			lossSum += sample.LossPercent
+			lossSamples++
+		}
+		if sample.Received > 0 {
+			latencySum += sample.Latency
+			latencySamples++
+			if sample.Latency > trend.MaxLatency {
+				trend.MaxLatency = sample.Latency
+			}
+		}
+		if sample.ADBChecked {
+			adbChecked++
+			if sample.ADBOK {
+				adbOK++
+			}
+		}
+	}
+
+	if lossSamples > 0 {
+		trend.AvgLossPercent = lossSum / float64(lossSamples)
+	}
+	trend.HealthyRatio = float64(healthy) / float64(len(samples))
+	if latencySamples > 0 {
+		trend.AvgLatency = latencySum / time.Duration(latencySamples)
+	}
+	if adbChecked > 0 {
+		trend.ADBAvailability = float64(adbOK) / float64(adbChecked)
+	}
+	return trend
+}
@@ -0,0 +1,429 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wumitech-com/mdcp_common/logger"
+	"github.com/wumitech-com/mdcp_server_operator/internal/config"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/phone"
+)
+
+const (
+	defaultInterval    = 60 // 默认探测周期（秒）
+	defaultPingCount   = 3  // 默认每次探测发送的ICMP数量
+	defaultHistorySize = 60 // 默认每台设备保留的历史样本数
+	defaultConcurrency = 32 // 默认同时进行的探测数上限
+	minProbeDelay      = time.Second
+)
+
+// 设备注册来源
+const (
+	SourceConfig = "config" // 配置文件
+	SourceRPC    = "rpc"    // 通过RPC注册
+)
+
+// ErrMonitorDisabled 后台监测未启用
+var ErrMonitorDisabled = errors.New("后台监测未启用")
+
+// PhoneState 被监测设备的当前状态和近期趋势
+type PhoneState struct {
+	IPAddress           string
+	Source              string
+	RegisteredAt        time.Time
+	Current             *Sample   // 最近一次探测结果，尚未探测时为nil
+	HealthSince         time.Time // 当前健康状态的开始时间
+	ConsecutiveFailures int       // 连续非健康的探测次数
+	SerialNumber        string    // 最近观测到的SN码
+	MACAddress          string    // 最近观测到的MAC地址
+	History             []Sample
+	Trend               Trend
+}
+
+// monitoredPhone 被监测设备的内部状态，字段由 Monitor.mu 保护
+type monitoredPhone struct {
+	ipAddress    string
+	source       string
+	registeredAt time.Time
+	cancel       context.CancelFunc
+	history      *history
+	current      *Sample
+	healthSince  time.Time
+	failures     int
+	serialNumber string
+	macAddress   string
+
+	identityCheckedAt time.Time // 仅由探测协程访问
+}
+
+// Monitor 云手机后台健康监测：维护设备注册表，按周期（带随机抖动）探测并在内存中保留历史
+type Monitor struct {
+	cfg          config.MonitorConfig
+	thresholds   phone.HealthThresholds
+	interval     time.Duration
+	jitter       time.Duration
+	pingTimeout  time.Duration
+	probeTimeout int32
+	sem          chan struct{}
+	events       *eventLog
+
+	mu     sync.RWMutex
+	ctx    context.Context // Start 之后有效，设备探测协程的父上下文
+	phones map[string]*monitoredPhone
+}
+
+// NewMonitor 创建后台监测
+func NewMonitor(cfg config.MonitorConfig, phoneCfg config.PhoneConfig) *Monitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.PingCount <= 0 {
+		cfg.PingCount = defaultPingCount
+	}
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = defaultHistorySize
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultConcurrency
+	}
+
+	return &Monitor{
+		cfg:          cfg,
+		thresholds:   phone.NewHealthThresholds(phoneCfg),
+		interval:     time.Duration(cfg.Interval) * time.Second,
+		jitter:       time.Duration(cfg.Jitter) * time.Second,
+		pingTimeout:  time.Duration(phoneCfg.PingTimeout) * time.Second,
+		probeTimeout: int32(phoneCfg.ADBTimeout),
+		sem:          make(chan struct{}, cfg.Concurrency),
+		events:       newEventLog(cfg.EventBufferSize),
+		phones:       make(map[string]*monitoredPhone),
+	}
+}
+
+// Enabled 是否启用后台监测
+func (m *Monitor) Enabled() bool {
+	return m.cfg.Enabled
+}
+
+// Start 注册配置中的设备并开始探测，ctx 取消时全部探测协程退出
+func (m *Monitor) Start(ctx context.Context) {
+	if !m.cfg.Enabled {
+		logger.InfoF("云手机后台监测未启用")
+		return
+	}
+
+	m.mu.Lock()
+	m.ctx = ctx
+	for _, p := range m.phones {
+		m.startLocked(p)
+	}
+	m.mu.Unlock()
+
+	for _, ip := range m.cfg.Phones {
+		if _, err := m.Register(ip, SourceConfig); err != nil {
+			logger.WarnF("注册配置中的监测设备失败: IP=%s, 错误=%v", ip, err)
+		}
+	}
+	logger.InfoF("云手机后台监测已启动: 周期=%v, 抖动=%v, 设备数=%d", m.interval, m.jitter, len(m.cfg.Phones))
+}
+
+// Register 注册被监测设备，已注册时返回 false
+func (m *Monitor) Register(ipAddress, source string) (bool, error) {
+	if !m.cfg.Enabled {
+		return false, ErrMonitorDisabled
+	}
+	if net.ParseIP(ipAddress) == nil {
+		return false, fmt.Errorf("无效的IP地址: %s", ipAddress)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.phones[ipAddress]; ok {
+		return false, nil
+	}
+
+	p := &monitoredPhone{
+		ipAddress:    ipAddress,
+		source:       source,
+		registeredAt: time.Now(),
+		history:      newHistory(m.cfg.HistorySize),
+	}
+	m.phones[ipAddress] = p
+	if m.ctx != nil {
+		m.startLocked(p)
+	}
+	return true, nil
+}
+
+// Unregister 取消监测设备，未注册时返回 false
+func (m *Monitor) Unregister(ipAddress string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.phones[ipAddress]
+	if !ok {
+		return false
+	}
+	if p.cancel != nil {
+		p.cancel()
+	}
+	delete(m.phones, ipAddress)
+	return true
+}
+
+// State 返回设备当前状态，historyLimit 限制返回的历史样本数（<=0 返回全部）
+func (m *Monitor) State(ipAddress string, historyLimit int) (*PhoneState, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.phones[ipAddress]
+	if !ok {
+		return nil, false
+	}
+	return p.snapshot(historyLimit), true
+}
+
+// States 按IP顺序返回全部被监测设备的状态
+func (m *Monitor) States(historyLimit int) []*PhoneState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	states := make([]*PhoneState, 0, len(m.phones))
+	for _, p := range m.phones {
+		states = append(states, p.snapshot(historyLimit))
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].IPAddress < states[j].IPAddress
+	})
+	return states
+}
+
+// snapshot 复制设备状态，调用方需持有读锁
+func (p *monitoredPhone) snapshot(historyLimit int) *PhoneState {
+	state := &PhoneState{
+		IPAddress:           p.ipAddress,
+		Source:              p.source,
+		RegisteredAt:        p.registeredAt,
+		HealthSince:         p.healthSince,
+		ConsecutiveFailures: p.failures,
+		SerialNumber:        p.serialNumber,
+		MACAddress:          p.macAddress,
+		History:             p.history.list(historyLimit),
+		Trend:               p.history.trend(),
+	}
+	if p.current != nil {
+		current := *p.current
+		state.Current = &current
+	}
+	return state
+}
+
+// startLocked 启动设备的探测协程，调用方需持有写锁
+func (m *Monitor) startLocked(p *monitoredPhone) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	p.cancel = cancel
+	go m.run(ctx, p)
+}
+
+// run 周期性探测单台设备，首次探测在一个周期内随机错开，避免所有设备同时探测
+func (m *Monitor) run(ctx context.Context, p *monitoredPhone) {
+	delay := rand.N(m.interval)
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		m.probe(ctx, p)
+
+		delay = m.interval
+		if m.jitter > 0 {
+			delay += rand.N(2*m.jitter) - m.jitter
+		}
+		if delay < minProbeDelay {
+			delay = minProbeDelay
+		}
+	}
+}
+
+// probe 探测一次并记录结果
+func (m *Monitor) probe(ctx context.Context, p *monitoredPhone) {
+	select {
+	case m.sem <- struct{}{}:
+		defer func() { <-m.sem }()
+	case <-ctx.Done():
+		return
+	}
+
+	sample := Sample{Time: time.Now()}
+	stats, err := phone.ExecutePing(ctx, p.ipAddress, phone.PingOptions{
+		Count:        int32(m.cfg.PingCount),
+		ProbeTimeout: m.pingTimeout,
+	})
+	sample.Health, sample.Reason = phone.ClassifyPingHealth(stats, m.thresholds)
+	sample.Latency = stats.Avg
+	sample.LossPercent = stats.LossPercent
+	sample.Sent, sample.Received = stats.Sent, stats.Received
+	sample.Jitter = stats.Jitter
+	if err != nil && stats.Sent == 0 {
+		sample.Reason = err.Error()
+	}
+
+	if m.cfg.ProbeADB {
+		result := phone.ProbePhone(ctx, p.ipAddress, phone.ProbeOptions{SkipICMP: true, Timeout: m.probeTimeout})
+		sample.ADBChecked = true
+		sample.ADBOK = result.FailedStage == ""
+		sample.FailedStage = result.FailedStage
+		for _, stage := range result.Stages {
+			if stage.Name == phone.ProbeStageADBHandshake {
+				sample.ADBLatency = stage.Latency
+			}
+		}
+
+		// ICMP可能被屏蔽，ADB可用时不判定为不可达；ADB不可用时最多判定为降级
+		switch {
+		case sample.ADBOK && sample.Health == phone.NetworkHealthUnreachable:
+			sample.Health, sample.Reason = phone.NetworkHealthDegraded, "ICMP无应答但ADB可用"
+		case !sample.ADBOK && sample.Health == phone.NetworkHealthHealthy:
+			sample.Health, sample.Reason = phone.NetworkHealthDegraded, "ADB不可用: "+sample.FailedStage
+		}
+	}
+
+	if ctx.Err() != nil {
+		// 设备已取消监测或服务正在关闭，丢弃未完成的样本
+		return
+	}
+	m.record(p, sample)
+
+	if m.cfg.IdentityInterval > 0 && (!sample.ADBChecked || sample.ADBOK) &&
+		time.Since(p.identityCheckedAt) >= time.Duration(m.cfg.IdentityInterval)*time.Second {
+		p.identityCheckedAt = time.Now()
+		m.checkIdentity(ctx, p.ipAddress)
+	}
+}
+
+// checkIdentity 通过ADB读取设备的SN码和MAC地址，与上次观测值比较
+func (m *Monitor) checkIdentity(ctx context.Context, ipAddress string) {
+	sn, err := phone.GetSerialNumberViaADB(ctx, ipAddress, m.probeTimeout)
+	if err != nil {
+		sn = ""
+	}
+	var mac string
+	if selected, _, err := phone.GetMACAddressViaADB(ctx, ipAddress, phone.MACQuery{}, m.probeTimeout); err == nil {
+		mac = selected.MACAddress
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	m.ObserveIdentity(ipAddress, sn, mac)
+}
+
+// ObserveIdentity 记录设备的SN码和MAC地址（空值表示未知），与上次观测值不同时发出变化事件
+// 除后台定期检查外，SN/MAC查询接口的结果也通过这里上报
+func (m *Monitor) ObserveIdentity(ipAddress, serialNumber, macAddress string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.phones[ipAddress]
+	if !ok {
+		return
+	}
+
+	if serialNumber != "" {
+		if p.serialNumber != "" && p.serialNumber != serialNumber {
+			logger.WarnF("云手机SN码变化: IP=%s, %s -> %s", ipAddress, p.serialNumber, serialNumber)
+			m.events.publish(Event{
+				Type:      EventSerialNumberChanged,
+				IPAddress: ipAddress,
+				OldValue:  p.serialNumber,
+				NewValue:  serialNumber,
+			})
+		}
+		p.serialNumber = serialNumber
+	}
+	if macAddress != "" {
+		if p.macAddress != "" && !phone.CompareMACAddress(p.macAddress, macAddress) {
+			logger.WarnF("云手机MAC地址变化: IP=%s, %s -> %s", ipAddress, p.macAddress, macAddress)
+			m.events.publish(Event{
+				Type:      EventMACAddressChanged,
+				IPAddress: ipAddress,
+				OldValue:  p.macAddress,
+				NewValue:  macAddress,
+			})
+		}
+		p.macAddress = macAddress
+	}
+}
+
+// ObservePortMapping 端口映射增删后调用，目标为被监测设备时发出事件
+func (m *Monitor) ObservePortMapping(ipAddress string, mappedPort int32, added bool) {
+	m.mu.RLock()
+	_, ok := m.phones[ipAddress]
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	eventType := EventPortMappingRemoved
+	if added {
+		eventType = EventPortMappingAdded
+	}
+	m.events.publish(Event{
+		Type:       eventType,
+		IPAddress:  ipAddress,
+		MappedPort: mappedPort,
+	})
+}
+
+// Subscribe 订阅设备事件，fromSequence>0 时先返回该序号之后仍保留的事件，用于断线重连后续传
+func (m *Monitor) Subscribe(fromSequence uint64, epoch int64) (*Subscription, []Event, error) {
+	if !m.cfg.Enabled {
+		return nil, nil, ErrMonitorDisabled
+	}
+	return m.events.subscribe(fromSequence, epoch)
+}
+
+// EventEpoch 返回事件序列的纪元，服务重启后变化，续传时用于判断序号是否仍然有效
+func (m *Monitor) EventEpoch() int64 {
+	return m.events.epoch
+}
+
+// record 记录样本并更新当前状态
+func (m *Monitor) record(p *monitoredPhone, sample Sample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case p.current == nil:
+		// 首次探测没有可比较的上一状态，只记录起始时间，不发布变化事件
+		p.healthSince = sample.Time
+	case p.current.Health != sample.Health:
+		previous := p.current.Health
+		logger.InfoF("云手机健康状态变化: IP=%s, %v -> %v, 原因=%s", p.ipAddress, previous, sample.Health, sample.Reason)
+		p.healthSince = sample.Time
+		m.events.publish(Event{
+			Time:      sample.Time,
+			Type:      EventHealthChanged,
+			IPAddress: p.ipAddress,
+			OldHealth: previous,
+			NewHealth: sample.Health,
+			Reason:    sample.Reason,
+		})
+	}
+	if sample.Health == phone.NetworkHealthHealthy {
+		p.failures = 0
+	} else {
+		p.failures++
+	}
+	p.current = &sample
+	p.history.add(sample)
+}
@@ -3,8 +3,10 @@ package phone
 import (
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,8 +15,60 @@ const (
 	adbPort           = 5555 // ADB连接端口
 )
 
-// GetSerialNumberViaADB 通过ADB获取设备的SN码
-func GetSerialNumberViaADB(ipAddress string, timeout int32) (string, error) {
+// deviceAddress 返回设备的ADB地址
+func deviceAddress(ipAddress string) string {
+	return fmt.Sprintf("%s:%d", ipAddress, adbPort)
+}
+
+// connectDevice 尝试 adb connect 设备（已连接时直接返回），忽略错误，由后续命令报告最终结果
+func connectDevice(ctx context.Context, deviceAddr string) {
+	connectCtx, connectCancel := context.WithTimeout(ctx, defaultADBTimeout*time.Second)
+	defer connectCancel()
+
+	connectCmd := exec.CommandContext(connectCtx, "adb", "connect", deviceAddr)
+	_, _ = connectCmd.CombinedOutput()
+}
+
+// runADBShell 执行 adb shell 命令，分别返回stdout、stderr和远端退出码
+// 非0退出码不视为错误，仅在命令无法执行、超时或被取消时返回错误
+func runADBShell(ctx context.Context, deviceAddr, command string) (string, string, int32, error) {
+	var stdout, stderr strings.Builder
+	cmd := exec.CommandContext(ctx, "adb", "-s", deviceAddr, "shell", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return stdout.String(), stderr.String(), -1, fmt.Errorf("执行命令超时或已取消: %v", ctx.Err())
+	}
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return stdout.String(), stderr.String(), int32(exitError.ExitCode()), nil
+		}
+		return stdout.String(), stderr.String(), -1, fmt.Errorf("执行命令失败: %v", err)
+	}
+	return stdout.String(), stderr.String(), 0, nil
+}
+
+// sleepContext 等待指定时长，ctx 取消时提前返回错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("已取消: %v", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
+// shellQuote 将参数转义为设备shell中的单个单引号字符串
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// GetSerialNumberViaADB 通过ADB获取设备的SN码，ctx 取消时终止
+func GetSerialNumberViaADB(ctx context.Context, ipAddress string, timeout int32) (string, error) {
 	if timeout <= 0 {
 		timeout = defaultADBTimeout
 	}
@@ -22,7 +76,7 @@ func GetSerialNumberViaADB(ipAddress string, timeout int32) (string, error) {
 	deviceAddr := fmt.Sprintf("%s:%d", ipAddress, adbPort)
 
 	// 先尝试连接设备
-	connectCtx, connectCancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	connectCtx, connectCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 	defer connectCancel()
 
 	connectCmd := exec.CommandContext(connectCtx, "adb", "connect", deviceAddr)
@@ -32,10 +86,12 @@ func GetSerialNumberViaADB(ipAddress string, timeout int32) (string, error) {
 	}
 
 	// 等待连接稳定
-	time.Sleep(500 * time.Millisecond)
+	if err := sleepContext(ctx, 500*time.Millisecond); err != nil {
+		return "", err
+	}
 
 	// 获取序列号
-	getpropCtx, getpropCancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	getpropCtx, getpropCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 	defer getpropCancel()
 
 	cmd := exec.CommandContext(getpropCtx, "adb", "-s", deviceAddr, "shell", "getprop", "ro.serialno")
@@ -52,72 +108,37 @@ func GetSerialNumberViaADB(ipAddress string, timeout int32) (string, error) {
 	return sn, nil
 }
 
-// GetMACAddressViaADB 通过ADB获取设备的MAC地址
-func GetMACAddressViaADB(ipAddress string, timeout int32) (string, error) {
+// GetMACAddressViaADB 通过ADB枚举设备全部网卡，按查询条件选出目标网卡，同时返回全部网卡
+func GetMACAddressViaADB(ctx context.Context, ipAddress string, query MACQuery, timeout int32) (*InterfaceMAC, []InterfaceMAC, error) {
 	if timeout <= 0 {
 		timeout = defaultADBTimeout
 	}
 
-	deviceAddr := fmt.Sprintf("%s:%d", ipAddress, adbPort)
-
-	// 尝试连接设备（如果已连接会直接返回）
-	connectCtx, connectCancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	defer connectCancel()
-
-	connectCmd := exec.CommandContext(connectCtx, "adb", "connect", deviceAddr)
-	_, _ = connectCmd.CombinedOutput() // 忽略错误，继续尝试获取MAC
-
-	// 等待连接稳定
-	time.Sleep(300 * time.Millisecond)
+	deviceAddr := deviceAddress(ipAddress)
+	connectDevice(ctx, deviceAddr)
 
-	// 方法1：通过 ip addr 获取（优先）
-	getipCtx1, getipCancel1 := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	defer getipCancel1()
+	shellCtx, shellCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer shellCancel()
 
-	// 获取 eth0 或 wlan0 的 MAC 地址
-	cmd1 := exec.CommandContext(getipCtx1, "adb", "-s", deviceAddr, "shell", "ip addr show eth0 | grep 'link/ether' | awk '{print $2}'")
-	output1, err1 := cmd1.CombinedOutput()
-
-	if err1 == nil && len(output1) > 0 {
-		mac := strings.TrimSpace(string(output1))
-		if mac != "" && len(mac) == 17 { // MAC 地址格式: xx:xx:xx:xx:xx:xx
-			return strings.ToLower(mac), nil
-		}
+	stdout, _, _, err := runADBShell(shellCtx, deviceAddr, listInterfacesScript)
+	if err != nil {
+		return nil, nil, fmt.Errorf("枚举网卡失败: %v", err)
 	}
 
-	// 方法2：直接读取系统文件
-	getipCtx2, getipCancel2 := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	defer getipCancel2()
-
-	cmd2 := exec.CommandContext(getipCtx2, "adb", "-s", deviceAddr, "shell", "cat /sys/class/net/eth0/address")
-	output2, err2 := cmd2.CombinedOutput()
-
-	if err2 == nil && len(output2) > 0 {
-		mac := strings.TrimSpace(string(output2))
-		if mac != "" && len(mac) == 17 {
-			return strings.ToLower(mac), nil
-		}
+	interfaces := parseInterfaceMACs(stdout)
+	if len(interfaces) == 0 {
+		return nil, nil, fmt.Errorf("无法通过ADB获取MAC地址: 未找到可用网卡")
 	}
 
-	// 方法3：尝试 wlan0（无线网络）
-	getipCtx3, getipCancel3 := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	defer getipCancel3()
-
-	cmd3 := exec.CommandContext(getipCtx3, "adb", "-s", deviceAddr, "shell", "cat /sys/class/net/wlan0/address")
-	output3, err3 := cmd3.CombinedOutput()
-
-	if err3 == nil && len(output3) > 0 {
-		mac := strings.TrimSpace(string(output3))
-		if mac != "" && len(mac) == 17 {
-			return strings.ToLower(mac), nil
-		}
+	selected, err := selectInterface(interfaces, ipAddress, query)
+	if err != nil {
+		return nil, interfaces, err
 	}
-
-	return "", fmt.Errorf("无法通过ADB获取MAC地址")
+	return selected, interfaces, nil
 }
 
-// ExecutePhoneCommand 执行云手机ADB命令
-func ExecutePhoneCommand(ipAddress, command string, timeout int32) (string, string, int32, error) {
+// ExecutePhoneCommand 执行云手机ADB命令，ctx 取消时终止
+func ExecutePhoneCommand(ctx context.Context, ipAddress, command string, timeout int32) (string, string, int32, error) {
 	if timeout <= 0 {
 		timeout = 30 // 默认30秒
 	}
@@ -125,17 +146,19 @@ func ExecutePhoneCommand(ipAddress, command string, timeout int32) (string, stri
 	deviceAddr := fmt.Sprintf("%s:%d", ipAddress, adbPort)
 
 	// 先尝试连接设备
-	connectCtx, connectCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	connectCtx, connectCancel := context.WithTimeout(ctx, 3*time.Second)
 	defer connectCancel()
 
 	connectCmd := exec.CommandContext(connectCtx, "adb", "connect", deviceAddr)
 	_, _ = connectCmd.CombinedOutput() // 忽略连接错误，继续执行命令
 
 	// 等待连接稳定
-	time.Sleep(300 * time.Millisecond)
+	if err := sleepContext(ctx, 300*time.Millisecond); err != nil {
+		return "", "", -1, err
+	}
 
 	// 执行命令
-	execCtx, execCancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	execCtx, execCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 	defer execCancel()
 
 	cmd := exec.CommandContext(execCtx, "adb", "-s", deviceAddr, "shell", command)
@@ -153,3 +176,98 @@ func ExecutePhoneCommand(ipAddress, command string, timeout int32) (string, stri
 
 	return string(output), "", exitCode, nil
 }
+
+// OutputStream 命令输出流类型
+type OutputStream int
+
+const (
+	OutputStdout OutputStream = iota // 标准输出
+	OutputStderr                     // 标准错误
+)
+
+const streamReadBufferSize = 32 * 1024 // 流式读取缓冲区大小
+
+// OutputHandler 命令输出回调，返回错误时终止命令
+type OutputHandler func(stream OutputStream, data []byte) error
+
+// StreamPhoneCommand 流式执行云手机ADB命令，输出到达即通过 onOutput 回调（串行调用）
+// ctx 取消（如客户端断开）时终止命令；timeout<=0 表示不设超时，仅依赖 ctx
+func StreamPhoneCommand(ctx context.Context, ipAddress, command string, timeout int32, onOutput OutputHandler) (int32, error) {
+	deviceAddr := deviceAddress(ipAddress)
+	connectDevice(ctx, deviceAddr)
+
+	// 执行命令
+	var (
+		execCtx    context.Context
+		execCancel context.CancelFunc
+	)
+	if timeout > 0 {
+		execCtx, execCancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	} else {
+		execCtx, execCancel = context.WithCancel(ctx)
+	}
+	defer execCancel()
+
+	cmd := exec.CommandContext(execCtx, "adb", "-s", deviceAddr, "shell", command)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return -1, fmt.Errorf("创建stdout管道失败: %v", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return -1, fmt.Errorf("创建stderr管道失败: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return -1, fmt.Errorf("启动命令失败: %v", err)
+	}
+
+	var (
+		mu         sync.Mutex
+		handlerErr error
+		wg         sync.WaitGroup
+	)
+	relay := func(stream OutputStream, r io.Reader) {
+		defer wg.Done()
+		buf := make([]byte, streamReadBufferSize)
+		for {
+			n, readErr := r.Read(buf)
+			if n > 0 {
+				mu.Lock()
+				if handlerErr == nil {
+					if err := onOutput(stream, buf[:n]); err != nil {
+						handlerErr = err
+						execCancel() // 回调失败（如客户端已断开），终止命令
+					}
+				}
+				mu.Unlock()
+			}
+			if readErr != nil {
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go relay(OutputStdout, stdout)
+	go relay(OutputStderr, stderr)
+	wg.Wait()
+
+	err = cmd.Wait()
+	if handlerErr != nil {
+		return -1, fmt.Errorf("转发命令输出失败: %v", handlerErr)
+	}
+	if ctx.Err() != nil {
+		return -1, fmt.Errorf("命令已取消: %v", ctx.Err())
+	}
+	if execCtx.Err() == context.DeadlineExceeded {
+		return -1, fmt.Errorf("命令执行超时")
+	}
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			// adb shell 透传远端退出码，非0退出码不视为执行失败
+			return int32(exitError.ExitCode()), nil
+		}
+		return -1, fmt.Errorf("执行命令失败: %v", err)
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,279 @@
+package phone
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wumitech-com/mdcp_server_operator/internal/config"
+)
+
+const (
+	adbKeyBits               = 2048 // ADB 只接受2048位RSA公钥
+	adbKeyWords              = adbKeyBits / 32
+	adbKeyComment            = "mdcp_server_operator"
+	adbVendorKeysEnv         = "ADB_VENDOR_KEYS" // adb server 启动时额外加载的私钥列表
+	deviceADBKeysPath        = "/data/misc/adb/adb_keys"
+	defaultADBKeyPushTimeout = 10 // 默认写入设备 adb_keys 超时时间（秒）
+)
+
+// ErrADBKeyNotConfigured 未配置运维方ADB密钥
+var ErrADBKeyNotConfigured = errors.New("未配置ADB密钥")
+
+// ADBKey 一对ADB RSA密钥
+type ADBKey struct {
+	Path        string // 私钥文件路径
+	PublicKey   string // Android adb_keys 格式的公钥行: "<base64> <注释>"
+	Fingerprint string // 公钥sha256指纹
+	blob        string // 公钥行中的base64部分，用于在 adb_keys 中查找
+}
+
+// ADBKeyManager 管理运维方的ADB密钥，当前密钥用于新授权，历史密钥在轮换期间继续用于连接尚未更新的设备
+type ADBKeyManager struct {
+	current  *ADBKey
+	previous []*ADBKey
+}
+
+// NewADBKeyManager 从配置路径（或secrets挂载）加载ADB密钥，未配置路径时返回未启用的管理器
+func NewADBKeyManager(cfg config.ADBKeyConfig) (*ADBKeyManager, error) {
+	m := &ADBKeyManager{}
+	if cfg.KeyPath == "" {
+		return m, nil
+	}
+
+	current, err := loadADBKey(cfg.KeyPath, cfg.GenerateIfMissing)
+	if err != nil {
+		return nil, err
+	}
+	m.current = current
+
+	for _, path := range cfg.PreviousKeyPaths {
+		key, err := loadADBKey(path, false)
+		if err != nil {
+			return nil, err
+		}
+		if key.blob != current.blob {
+			m.previous = append(m.previous, key)
+		}
+	}
+	return m, nil
+}
+
+// Enabled 是否配置了运维方密钥
+func (m *ADBKeyManager) Enabled() bool {
+	return m.current != nil
+}
+
+// Current 返回当前密钥
+func (m *ADBKeyManager) Current() *ADBKey {
+	return m.current
+}
+
+// Previous 返回轮换中的历史密钥
+func (m *ADBKeyManager) Previous() []*ADBKey {
+	return m.previous
+}
+
+// Apply 通过 ADB_VENDOR_KEYS 让 adb server 使用当前密钥和历史密钥，并重启 adb server 使其生效
+// 需在执行任何 adb 命令前调用
+func (m *ADBKeyManager) Apply(ctx context.Context) error {
+	if !m.Enabled() {
+		return nil
+	}
+
+	paths := []string{m.current.Path}
+	for _, key := range m.previous {
+		paths = append(paths, key.Path)
+	}
+	if err := os.Setenv(adbVendorKeysEnv, strings.Join(paths, ":")); err != nil {
+		return fmt.Errorf("设置%s失败: %v", adbVendorKeysEnv, err)
+	}
+
+	// 已运行的 adb server 不会重新加载密钥
+	killCtx, killCancel := context.WithTimeout(ctx, defaultADBTimeout*time.Second)
+	defer killCancel()
+	_, _ = exec.CommandContext(killCtx, "adb", "kill-server").CombinedOutput()
+	return nil
+}
+
+// loadADBKey 加载PEM格式私钥，文件不存在且允许生成时生成新密钥并写入
+func loadADBKey(path string, generateIfMissing bool) (*ADBKey, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && generateIfMissing {
+		return generateADBKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取ADB私钥失败: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("ADB私钥不是PEM格式: %s", path)
+	}
+
+	var privateKey *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("ADB私钥不是RSA密钥: %s", path)
+		}
+		privateKey = rsaKey
+	} else if privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("解析ADB私钥失败: %s: %v", path, err)
+	}
+
+	return newADBKey(path, &privateKey.PublicKey)
+}
+
+// generateADBKey 生成2048位RSA密钥，私钥以PKCS#8 PEM写入 path，公钥以 adb 格式写入 path.pub
+func generateADBKey(path string) (*ADBKey, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, adbKeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("生成ADB密钥失败: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("编码ADB私钥失败: %v", err)
+	}
+
+	key, err := newADBKey(path, &privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("创建ADB密钥目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, fmt.Errorf("写入ADB私钥失败: %v", err)
+	}
+	if err := os.WriteFile(path+".pub", []byte(key.PublicKey+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("写入ADB公钥失败: %v", err)
+	}
+	return key, nil
+}
+
+// newADBKey 由RSA公钥计算 adb_keys 格式的公钥行和指纹
+func newADBKey(path string, publicKey *rsa.PublicKey) (*ADBKey, error) {
+	encoded, err := encodeAndroidPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	blob := base64.StdEncoding.EncodeToString(encoded)
+	sum := sha256.Sum256(encoded)
+	return &ADBKey{
+		Path:        path,
+		PublicKey:   blob + " " + adbKeyComment,
+		Fingerprint: hex.EncodeToString(sum[:]),
+		blob:        blob,
+	}, nil
+}
+
+// encodeAndroidPublicKey 按 Android RSAPublicKey 结构编码公钥:
+// 模数字长、-1/n[0] mod 2^32、模数、R^2 mod n（均为小端32位字）以及公钥指数
+func encodeAndroidPublicKey(publicKey *rsa.PublicKey) ([]byte, error) {
+	if publicKey.N.BitLen() != adbKeyBits {
+		return nil, fmt.Errorf("ADB密钥必须为%d位RSA密钥，实际为%d位", adbKeyBits, publicKey.N.BitLen())
+	}
+
+	r32 := new(big.Int).Lsh(big.NewInt(1), 32)
+	n0inv := new(big.Int).ModInverse(new(big.Int).Mod(publicKey.N, r32), r32)
+	n0inv.Sub(r32, n0inv)
+
+	rr := new(big.Int).Lsh(big.NewInt(1), adbKeyBits*2)
+	rr.Mod(rr, publicKey.N)
+
+	buf := make([]byte, 0, 4+4+adbKeyBits/8*2+4)
+	buf = binary.LittleEndian.AppendUint32(buf, adbKeyWords)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(n0inv.Uint64()))
+	buf = append(buf, littleEndianBytes(publicKey.N, adbKeyBits/8)...)
+	buf = append(buf, littleEndianBytes(rr, adbKeyBits/8)...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(publicKey.E))
+	return buf, nil
+}
+
+// littleEndianBytes 将大整数编码为定长小端字节序
+func littleEndianBytes(n *big.Int, size int) []byte {
+	b := n.FillBytes(make([]byte, size))
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
+
+// InstallPublicKey 将当前公钥写入设备 adb_keys（已存在则跳过），removePrevious 为 true 时同时移除历史公钥
+// 需要设备已信任运维方的某个密钥；非root的adbd通过 su 写入
+func (m *ADBKeyManager) InstallPublicKey(ctx context.Context, ipAddress string, removePrevious bool, timeout int32) (bool, int, error) {
+	if !m.Enabled() {
+		return false, 0, ErrADBKeyNotConfigured
+	}
+	if timeout <= 0 {
+		timeout = defaultADBKeyPushTimeout
+	}
+
+	var stale []string
+	if removePrevious {
+		for _, key := range m.previous {
+			if key.blob != m.current.blob {
+				stale = append(stale, shellQuote(key.blob))
+			}
+		}
+	}
+
+	// 先追加并确认当前公钥已写入，再移除历史公钥；追加失败时保留历史公钥，避免设备不再信任运维方任何密钥
+	current := shellQuote(m.current.blob)
+	var script strings.Builder
+	script.WriteString("f=" + deviceADBKeysPath + "\n")
+	script.WriteString("touch $f || exit 1\n")
+	script.WriteString("removed=0\n")
+	script.WriteString("if grep -qF " + current + " $f; then added=0; else echo " + shellQuote(m.current.PublicKey) + " >> $f || exit 1; added=1; fi\n")
+	script.WriteString("grep -qF " + current + " $f || exit 1\n")
+	if len(stale) > 0 {
+		script.WriteString("for k in " + strings.Join(stale, " ") + "; do\n")
+		script.WriteString("  if grep -qF \"$k\" $f; then grep -vF \"$k\" $f > $f.tmp && grep -qF " + current + " $f.tmp && cat $f.tmp > $f && removed=$((removed+1)); rm -f $f.tmp; fi\n")
+		script.WriteString("done\n")
+	}
+	script.WriteString("chown system:shell $f 2>/dev/null; chmod 0640 $f\n")
+	script.WriteString("echo \"@@ADBKEY $added $removed\"\n")
+
+	body := shellQuote(script.String())
+	command := "if [ \"$(id -u)\" = 0 ]; then sh -c " + body + "; else su 0 sh -c " + body + "; fi"
+
+	deviceAddr := deviceAddress(ipAddress)
+	connectDevice(ctx, deviceAddr)
+
+	execCtx, execCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer execCancel()
+
+	stdout, stderr, exitCode, err := runADBShell(execCtx, deviceAddr, command)
+	if err != nil {
+		return false, 0, fmt.Errorf("写入adb_keys失败: %v", err)
+	}
+
+	idx := strings.Index(stdout, "@@ADBKEY ")
+	if exitCode != 0 || idx < 0 {
+		return false, 0, fmt.Errorf("写入adb_keys失败: 退出码=%d, %s", exitCode, strings.TrimSpace(stderr+stdout))
+	}
+	fields := strings.Fields(stdout[idx:])
+	if len(fields) < 3 {
+		return false, 0, fmt.Errorf("解析adb_keys写入结果失败: %s", strings.TrimSpace(stdout))
+	}
+	removed, _ := strconv.Atoi(fields[2])
+	return fields[1] == "1", removed, nil
+}
@@ -0,0 +1,102 @@
+package phone
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"testing"
+)
+
+// testADBKeyModulus 固定的2048位RSA模数（公钥指数65537），用于校验 adb_keys 编码
+const testADBKeyModulus = "" +
+	"efeada31b4028519229367d0b0857fdc9a682cb91f1d9e48504a16c4205022fc" +
+	"3d312f32c347628dd90ce7c6575dd754be2e25d1ff29590032a8052f6436d526" +
+	"c636088bb31a7a960a1468a4db86344446e76f3ebfb65a12341a21d56974f281" +
+	"d7f61e513dc6ba7c817c79b9563f40f662fac15f1b45d306774162a817ea1368" +
+	"0b778ee0d976d69a38221dccd7f8722596df6a499b465c29e521131ef8d9d41d" +
+	"c91a6db39a56de2bb5673af34a1dcb69cf704bfc16a22599fbfe75bb8a8f8053" +
+	"3184bbfd17b9ad9c800d0799e6a7a333139021bcbb5f8d90dc576753b36f2797" +
+	"60fb83266c7742da134718b84a369702a20c074b03058d56513c53d0745afc83"
+
+// testADBKeyBlob 与 testADBKeyModulus 对应的 adb_keys 公钥行base64部分，
+// 按 AOSP libcrypto_utils android_pubkey_encode 的结构独立计算
+const testADBKeyBlob = "" +
+	"QAAAANXN3Z2D/Fp00FM8UVaNBQNLBwyiApc2SrgYRxPaQndsJoP7YJcnb7NTZ1fc" +
+	"kI1fu7whkBMzo6fmmQcNgJytuRf9u4QxU4CPirt1/vuZJaIW/Etwz2nLHUrzOme1" +
+	"K95WmrNtGskd1Nn4HhMh5SlcRptJat+WJXL418wdIjia1nbZ4I53C2gT6heoYkF3" +
+	"BtNFG1/B+mL2QD9WuXl8gXy6xj1RHvbXgfJ0adUhGjQSWra/Pm/nRkQ0htukaBQK" +
+	"lnoas4sINsYm1TZkLwWoMgBZKf/RJS6+VNddV8bnDNmNYkfDMi8xPfwiUCDEFkpQ" +
+	"SJ4dH7ksaJrcf4Ww0GeTIhmFArQx2urvIqDb9hoiUC5pg1XhhBdcXNtlGKGnDsqQ" +
+	"MX/FUeGXzx8RW9nQEi+wgXwbI9EBBy8l9vMD/dAf2weQFHlhMs8QWp4welNhjP3Y" +
+	"oei+quXk8KevBKbi2ZqwMxOx26OS98VA2a8KBQJNGJVoJCqUMS0TxAoNpmnXYmZ6" +
+	"KP0NHrdt+JxoWIdJVx5EMKs0gCP1+5ZJx/nrpS5JFCHaw0Ijge8+VaST5U8LdRbo" +
+	"qgE80kE7nbg+rxSagDSZRLXGdI+vIYQMrTdtK56R/E6tjr13xNjFJS8Mku9PINvb" +
+	"zRrP3GuFx4UTUYPhQvcCWtpgHWpsuQ+UwFuZVDXR3vlHeTzwuEh0YwEAAQA="
+
+func testADBPublicKey(t *testing.T) *rsa.PublicKey {
+	t.Helper()
+	n, ok := new(big.Int).SetString(testADBKeyModulus, 16)
+	if !ok {
+		t.Fatal("invalid test modulus")
+	}
+	return &rsa.PublicKey{N: n, E: 65537}
+}
+
+func TestEncodeAndroidPublicKeyVector(t *testing.T) {
+	encoded, err := encodeAndroidPublicKey(testADBPublicKey(t))
+	if err != nil {
+		t.Fatalf("encodeAndroidPublicKey: %v", err)
+	}
+	if got := base64.StdEncoding.EncodeToString(encoded); got != testADBKeyBlob {
+		t.Errorf("encodeAndroidPublicKey blob mismatch:\n got %s\nwant %s", got, testADBKeyBlob)
+	}
+}
+
+func TestEncodeAndroidPublicKeyLayout(t *testing.T) {
+	publicKey := testADBPublicKey(t)
+	encoded, err := encodeAndroidPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("encodeAndroidPublicKey: %v", err)
+	}
+
+	const size = adbKeyBits / 8
+	if len(encoded) != 4+4+size*2+4 {
+		t.Fatalf("encoded length = %d, want %d", len(encoded), 4+4+size*2+4)
+	}
+	if words := binary.LittleEndian.Uint32(encoded[0:4]); words != adbKeyWords {
+		t.Errorf("modulus words = %d, want %d", words, adbKeyWords)
+	}
+
+	// n0inv * n[0] ≡ -1 (mod 2^32)
+	n0inv := binary.LittleEndian.Uint32(encoded[4:8])
+	n0 := uint32(new(big.Int).And(publicKey.N, big.NewInt(0xffffffff)).Uint64())
+	if n0inv*n0 != 0xffffffff {
+		t.Errorf("n0inv*n[0] = %#x, want 0xffffffff", n0inv*n0)
+	}
+
+	fromLittleEndian := func(b []byte) *big.Int {
+		be := make([]byte, len(b))
+		for i := range b {
+			be[len(b)-1-i] = b[i]
+		}
+		return new(big.Int).SetBytes(be)
+	}
+	if n := fromLittleEndian(encoded[8 : 8+size]); n.Cmp(publicKey.N) != 0 {
+		t.Error("modulus does not round-trip")
+	}
+	rr := new(big.Int).Exp(big.NewInt(2), big.NewInt(adbKeyBits*2), publicKey.N)
+	if got := fromLittleEndian(encoded[8+size : 8+size*2]); got.Cmp(rr) != 0 {
+		t.Error("rr != 2^4096 mod n")
+	}
+	if e := binary.LittleEndian.Uint32(encoded[8+size*2:]); e != 65537 {
+		t.Errorf("exponent = %d, want 65537", e)
+	}
+}
+
+func TestEncodeAndroidPublicKeyRejectsWrongSize(t *testing.T) {
+	publicKey := &rsa.PublicKey{N: new(big.Int).Lsh(big.NewInt(1), 1023), E: 65537}
+	if _, err := encodeAndroidPublicKey(publicKey); err == nil {
+		t.Error("encodeAndroidPublicKey accepted a 1024-bit key")
+	}
+}
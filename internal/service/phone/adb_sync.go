@@ -0,0 +1,416 @@
+package phone
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	adbServerAddr      = "127.0.0.1:5037" // 本地adb server地址
+	syncMaxChunkSize   = 64 * 1024        // ADB sync协议单个DATA包最大长度
+	syncMaxPathLength  = 1024             // ADB sync协议路径最大长度
+	defaultFileMode    = 0o644            // 推送文件默认权限
+	syncRegularFileBit = 0o100000         // S_IFREG
+)
+
+// FileStat 云手机文件信息
+type FileStat struct {
+	Mode    os.FileMode
+	Size    int64
+	ModTime time.Time
+}
+
+// FileTransferResult 文件传输结果
+type FileTransferResult struct {
+	Bytes    int64       // 传输字节数
+	Mode     os.FileMode // 文件权限
+	SHA256   string      // 传输内容的sha256
+	Verified bool        // 是否已与设备侧sha256校验一致
+}
+
+// ProgressHandler 传输进度回调，参数为已传输字节数
+type ProgressHandler func(transferred int64)
+
+// SyncConn ADB sync协议连接
+type SyncConn struct {
+	conn     net.Conn
+	stopFunc func() bool
+}
+
+// OpenSync 连接设备并进入ADB sync模式，ctx 取消时连接随之关闭
+func OpenSync(ctx context.Context, ipAddress string) (*SyncConn, error) {
+	deviceAddr := deviceAddress(ipAddress)
+	connectDevice(ctx, deviceAddr)
+
+	conn, err := dialADBDevice(ctx, deviceAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sendHostRequest(conn, "sync:"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("进入sync模式失败: %v", err)
+	}
+
+	return &SyncConn{
+		conn:     conn,
+		stopFunc: context.AfterFunc(ctx, func() { conn.Close() }),
+	}, nil
+}
+
+// dialADBDevice 连接本地adb server并切换到指定设备的传输通道
+func dialADBDevice(ctx context.Context, deviceAddr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", adbServerAddr)
+	if err != nil {
+		return nil, fmt.Errorf("连接adb server失败: %v", err)
+	}
+
+	if err := sendHostRequest(conn, "host:transport:"+deviceAddr); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("切换设备传输通道失败: %v", err)
+	}
+	return conn, nil
+}
+
+// sendHostRequest 发送adb host协议请求（4位十六进制长度前缀）并读取OKAY/FAIL状态
+func sendHostRequest(conn net.Conn, request string) error {
+	if _, err := fmt.Fprintf(conn, "%04x%s", len(request), request); err != nil {
+		return err
+	}
+
+	status := make([]byte, 4)
+	if _, err := io.ReadFull(conn, status); err != nil {
+		return err
+	}
+	switch string(status) {
+	case "OKAY":
+		return nil
+	case "FAIL":
+		lenHex := make([]byte, 4)
+		if _, err := io.ReadFull(conn, lenHex); err != nil {
+			return err
+		}
+		msgLen, err := strconv.ParseUint(string(lenHex), 16, 32)
+		if err != nil {
+			return fmt.Errorf("无效的FAIL消息长度: %q", lenHex)
+		}
+		msg := make([]byte, msgLen)
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return err
+		}
+		return fmt.Errorf("%s", msg)
+	default:
+		return fmt.Errorf("未知的adb响应: %q", status)
+	}
+}
+
+// writeSyncPacket 写入sync协议包：4字节ID + 4字节小端长度/参数 + 数据
+func (s *SyncConn) writeSyncPacket(id string, arg uint32, data []byte) error {
+	header := make([]byte, 8)
+	copy(header, id)
+	binary.LittleEndian.PutUint32(header[4:], arg)
+	if _, err := s.conn.Write(header); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		if _, err := s.conn.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSyncHeader 读取sync协议包头
+func (s *SyncConn) readSyncHeader() (string, uint32, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(s.conn, header); err != nil {
+		return "", 0, err
+	}
+	return string(header[:4]), binary.LittleEndian.Uint32(header[4:]), nil
+}
+
+// readSyncFail 读取FAIL包中的错误信息
+func (s *SyncConn) readSyncFail(length uint32) error {
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(s.conn, msg); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// Stat 获取设备文件信息，文件不存在时返回 os.ErrNotExist
+func (s *SyncConn) Stat(remotePath string) (*FileStat, error) {
+	if len(remotePath) > syncMaxPathLength {
+		return nil, fmt.Errorf("路径过长: %d", len(remotePath))
+	}
+	if err := s.writeSyncPacket("STAT", uint32(len(remotePath)), []byte(remotePath)); err != nil {
+		return nil, err
+	}
+
+	resp := make([]byte, 16)
+	if _, err := io.ReadFull(s.conn, resp); err != nil {
+		return nil, err
+	}
+	if string(resp[:4]) != "STAT" {
+		return nil, fmt.Errorf("未知的STAT响应: %q", resp[:4])
+	}
+
+	mode := binary.LittleEndian.Uint32(resp[4:8])
+	if mode == 0 {
+		return nil, os.ErrNotExist
+	}
+	return &FileStat{
+		Mode:    os.FileMode(mode & 0o777),
+		Size:    int64(binary.LittleEndian.Uint32(resp[8:12])),
+		ModTime: time.Unix(int64(binary.LittleEndian.Uint32(resp[12:16])), 0),
+	}, nil
+}
+
+// Send 将 r 中的数据写入设备文件，返回写入字节数
+func (s *SyncConn) Send(remotePath string, mode os.FileMode, mtime time.Time, r io.Reader, onProgress ProgressHandler) (int64, error) {
+	target := fmt.Sprintf("%s,%d", remotePath, syncRegularFileBit|uint32(mode.Perm()))
+	if len(target) > syncMaxPathLength {
+		return 0, fmt.Errorf("路径过长: %d", len(remotePath))
+	}
+	if err := s.writeSyncPacket("SEND", uint32(len(target)), []byte(target)); err != nil {
+		return 0, err
+	}
+
+	var written int64
+	buf := make([]byte, syncMaxChunkSize)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			if err := s.writeSyncPacket("DATA", uint32(n), buf[:n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+			if onProgress != nil {
+				onProgress(written)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
+
+	if err := s.writeSyncPacket("DONE", uint32(mtime.Unix()), nil); err != nil {
+		return written, err
+	}
+
+	id, length, err := s.readSyncHeader()
+	if err != nil {
+		return written, err
+	}
+	switch id {
+	case "OKAY":
+		return written, nil
+	case "FAIL":
+		return written, s.readSyncFail(length)
+	default:
+		return written, fmt.Errorf("未知的SEND响应: %q", id)
+	}
+}
+
+// Recv 读取设备文件写入 w，返回读取字节数
+func (s *SyncConn) Recv(remotePath string, w io.Writer, onProgress ProgressHandler) (int64, error) {
+	if len(remotePath) > syncMaxPathLength {
+		return 0, fmt.Errorf("路径过长: %d", len(remotePath))
+	}
+	if err := s.writeSyncPacket("RECV", uint32(len(remotePath)), []byte(remotePath)); err != nil {
+		return 0, err
+	}
+
+	var received int64
+	buf := make([]byte, syncMaxChunkSize)
+	for {
+		id, length, err := s.readSyncHeader()
+		if err != nil {
+			return received, err
+		}
+		switch id {
+		case "DATA":
+			if length > syncMaxChunkSize {
+				return received, fmt.Errorf("DATA包过大: %d", length)
+			}
+			if _, err := io.ReadFull(s.conn, buf[:length]); err != nil {
+				return received, err
+			}
+			if _, err := w.Write(buf[:length]); err != nil {
+				return received, err
+			}
+			received += int64(length)
+			if onProgress != nil {
+				onProgress(received)
+			}
+		case "DONE":
+			return received, nil
+		case "FAIL":
+			return received, s.readSyncFail(length)
+		default:
+			return received, fmt.Errorf("未知的RECV响应: %q", id)
+		}
+	}
+}
+
+// Close 退出sync模式并关闭连接
+func (s *SyncConn) Close() error {
+	s.stopFunc()
+	_ = s.writeSyncPacket("QUIT", 0, nil)
+	return s.conn.Close()
+}
+
+// limitedReader 超过上限时返回错误的 Reader，用于限制推送文件大小
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.limit > 0 && l.read > l.limit {
+		return n, fmt.Errorf("文件大小超过限制(%d字节)", l.limit)
+	}
+	return n, err
+}
+
+// limitedWriter 超过上限时返回错误的 Writer，用于限制拉取文件大小
+type limitedWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	l.written += int64(len(p))
+	if l.limit > 0 && l.written > l.limit {
+		return 0, fmt.Errorf("文件大小超过限制(%d字节)", l.limit)
+	}
+	return l.w.Write(p)
+}
+
+// PushFile 通过ADB sync协议推送文件到云手机，maxSize<=0 表示不限制大小
+// expectedSHA256 非空时校验传输内容，不一致则删除设备上的文件；
+// 推送完成后与设备侧 sha256sum 比对，设备不支持 sha256sum 时 Verified 为 false
+func PushFile(ctx context.Context, ipAddress, remotePath string, mode os.FileMode, r io.Reader, maxSize int64, expectedSHA256 string, onProgress ProgressHandler) (*FileTransferResult, error) {
+	if mode.Perm() == 0 {
+		mode = defaultFileMode
+	}
+
+	syncConn, err := OpenSync(ctx, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer syncConn.Close()
+
+	hash := sha256.New()
+	reader := io.TeeReader(&limitedReader{r: r, limit: maxSize}, hash)
+	written, err := syncConn.Send(remotePath, mode, time.Now(), reader, onProgress)
+	if err != nil {
+		return nil, fmt.Errorf("推送文件失败(已传输%d字节): %v", written, err)
+	}
+
+	result := &FileTransferResult{
+		Bytes:  written,
+		Mode:   mode.Perm(),
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}
+	if expectedSHA256 != "" && !strings.EqualFold(expectedSHA256, result.SHA256) {
+		removeRemoteFile(ctx, ipAddress, remotePath)
+		return result, fmt.Errorf("文件校验失败: 期望sha256=%s, 实际sha256=%s", expectedSHA256, result.SHA256)
+	}
+	if err := verifyRemoteSHA256(ctx, ipAddress, remotePath, result); err != nil {
+		removeRemoteFile(ctx, ipAddress, remotePath)
+		return result, err
+	}
+	return result, nil
+}
+
+// removeRemoteFile 删除设备上的文件（尽力而为），用于清理校验失败的推送结果
+func removeRemoteFile(ctx context.Context, ipAddress, remotePath string) {
+	shellCtx, shellCancel := context.WithTimeout(ctx, defaultADBTimeout*time.Second)
+	defer shellCancel()
+
+	_, _, _, _ = runADBShell(shellCtx, deviceAddress(ipAddress), "rm -f "+shellQuote(remotePath))
+}
+
+// PullFile 通过ADB sync协议从云手机拉取文件写入 w，maxSize<=0 表示不限制大小
+// onStat 在传输开始前回调文件信息；拉取完成后与设备侧 sha256sum 比对
+func PullFile(ctx context.Context, ipAddress, remotePath string, w io.Writer, maxSize int64, onStat func(*FileStat) error, onProgress ProgressHandler) (*FileTransferResult, error) {
+	syncConn, err := OpenSync(ctx, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer syncConn.Close()
+
+	stat, err := syncConn.Stat(remotePath)
+	if err != nil {
+		if err == os.ErrNotExist {
+			return nil, fmt.Errorf("文件不存在: %s", remotePath)
+		}
+		return nil, fmt.Errorf("获取文件信息失败: %v", err)
+	}
+	if maxSize > 0 && stat.Size > maxSize {
+		return nil, fmt.Errorf("文件大小%d字节超过限制(%d字节)", stat.Size, maxSize)
+	}
+	if onStat != nil {
+		if err := onStat(stat); err != nil {
+			return nil, err
+		}
+	}
+
+	hash := sha256.New()
+	writer := &limitedWriter{w: io.MultiWriter(w, hash), limit: maxSize}
+	received, err := syncConn.Recv(remotePath, writer, onProgress)
+	if err != nil {
+		return nil, fmt.Errorf("拉取文件失败(已传输%d字节): %v", received, err)
+	}
+
+	result := &FileTransferResult{
+		Bytes:  received,
+		Mode:   stat.Mode,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}
+	if err := verifyRemoteSHA256(ctx, ipAddress, remotePath, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// verifyRemoteSHA256 计算设备侧文件sha256并与传输内容比对，不一致时返回错误
+func verifyRemoteSHA256(ctx context.Context, ipAddress, remotePath string, result *FileTransferResult) error {
+	shellCtx, shellCancel := context.WithTimeout(ctx, 60*time.Second)
+	defer shellCancel()
+
+	stdout, _, exitCode, err := runADBShell(shellCtx, deviceAddress(ipAddress), "sha256sum "+shellQuote(remotePath))
+	if err != nil || exitCode != 0 {
+		// 部分老版本系统没有 sha256sum，无法校验但不视为传输失败
+		return nil
+	}
+
+	fields := strings.Fields(stdout)
+	if len(fields) == 0 {
+		return nil
+	}
+	if !strings.EqualFold(fields[0], result.SHA256) {
+		return fmt.Errorf("文件校验失败: 设备侧sha256=%s, 传输内容sha256=%s", fields[0], result.SHA256)
+	}
+
+	result.Verified = true
+	return nil
+}
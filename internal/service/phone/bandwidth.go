@@ -0,0 +1,208 @@
+package phone
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	DefaultBandwidthPayloadSize = 64 * 1024 * 1024 // 默认每个方向传输的数据量上限
+	DefaultBandwidthDuration    = 10 * time.Second // 默认每个方向的传输时长上限
+	bandwidthRemoteDir          = "/data/local/tmp"
+	bandwidthBlockSize          = syncMaxChunkSize
+)
+
+// errBandwidthDurationReached 达到传输时长上限，用于提前结束拉取
+var errBandwidthDurationReached = errors.New("达到传输时长上限")
+
+// BandwidthOptions 带宽测试参数
+type BandwidthOptions struct {
+	PayloadSize  int64         // 每个方向最多传输的字节数
+	Duration     time.Duration // 每个方向的传输时长上限，达到后提前结束
+	HostToPhone  bool          // 测试宿主机到云手机（推送）
+	PhoneToHost  bool          // 测试云手机到宿主机（拉取）
+	SetupTimeout time.Duration // 设备侧准备和清理命令的超时时间
+}
+
+// Throughput 单个方向的吞吐量测试结果
+type Throughput struct {
+	Bytes     int64
+	Duration  time.Duration
+	Mbps      float64
+	Truncated bool // 达到时长上限提前结束
+	Err       error
+}
+
+// BandwidthResult 带宽测试结果，未测试的方向为nil
+type BandwidthResult struct {
+	HostToPhone *Throughput
+	PhoneToHost *Throughput
+}
+
+// finish 根据字节数和耗时计算吞吐量
+func (t *Throughput) finish(start time.Time) {
+	t.Duration = time.Since(start)
+	if t.Duration > 0 {
+		t.Mbps = float64(t.Bytes) * 8 / t.Duration.Seconds() / 1e6
+	}
+}
+
+// payloadReader 循环输出随机数据块，达到字节数或时长上限时结束
+type payloadReader struct {
+	block     []byte
+	remaining int64
+	deadline  time.Time
+	truncated bool
+}
+
+func (r *payloadReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if !time.Now().Before(r.deadline) {
+		r.truncated = true
+		return 0, io.EOF
+	}
+	n := len(p)
+	if n > len(r.block) {
+		n = len(r.block)
+	}
+	if int64(n) > r.remaining {
+		n = int(r.remaining)
+	}
+	copy(p, r.block[:n])
+	r.remaining -= int64(n)
+	return n, nil
+}
+
+// deadlineWriter 丢弃数据，超过时长上限后返回 errBandwidthDurationReached
+type deadlineWriter struct {
+	deadline time.Time
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	if !time.Now().Before(w.deadline) {
+		return 0, errBandwidthDurationReached
+	}
+	return len(p), nil
+}
+
+// MeasureBandwidth 通过ADB sync连接测试宿主机与云手机之间的TCP吞吐量：
+// 推送随机数据到设备临时文件测上行，再拉取该文件测下行，每个方向受字节数和时长双重限制，结束后删除临时文件
+func MeasureBandwidth(ctx context.Context, ipAddress string, opts BandwidthOptions) (*BandwidthResult, error) {
+	if opts.PayloadSize <= 0 {
+		opts.PayloadSize = DefaultBandwidthPayloadSize
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = DefaultBandwidthDuration
+	}
+	if opts.SetupTimeout <= 0 {
+		opts.SetupTimeout = defaultADBTimeout * time.Second
+	}
+	if !opts.HostToPhone && !opts.PhoneToHost {
+		opts.HostToPhone, opts.PhoneToHost = true, true
+	}
+
+	block := make([]byte, bandwidthBlockSize)
+	if _, err := rand.Read(block); err != nil {
+		return nil, fmt.Errorf("生成测试数据失败: %v", err)
+	}
+
+	remotePath := fmt.Sprintf("%s/mdcp_bandwidth_%d.bin", bandwidthRemoteDir, time.Now().UnixNano())
+	defer removeRemoteFile(context.WithoutCancel(ctx), ipAddress, remotePath)
+
+	result := &BandwidthResult{}
+	pushed := false
+	if opts.HostToPhone {
+		result.HostToPhone = measureHostToPhone(ctx, ipAddress, remotePath, block, opts)
+		pushed = result.HostToPhone.Err == nil && result.HostToPhone.Bytes > 0
+	}
+	if ctx.Err() != nil {
+		return result, fmt.Errorf("带宽测试已取消: %v", ctx.Err())
+	}
+
+	if opts.PhoneToHost {
+		if !pushed {
+			// 未推送测试文件时在设备上生成，拉取内容不压缩，零字节数据不影响结果
+			if err := createRemotePayload(ctx, ipAddress, remotePath, opts); err != nil {
+				result.PhoneToHost = &Throughput{Err: err}
+				return result, nil
+			}
+		}
+		result.PhoneToHost = measurePhoneToHost(ctx, ipAddress, remotePath, opts)
+	}
+	if ctx.Err() != nil {
+		return result, fmt.Errorf("带宽测试已取消: %v", ctx.Err())
+	}
+	return result, nil
+}
+
+// measureHostToPhone 推送随机数据测试宿主机到云手机的吞吐量，计时包含设备确认写入完成
+func measureHostToPhone(ctx context.Context, ipAddress, remotePath string, block []byte, opts BandwidthOptions) *Throughput {
+	throughput := &Throughput{}
+	syncConn, err := OpenSync(ctx, ipAddress)
+	if err != nil {
+		throughput.Err = err
+		return throughput
+	}
+	defer syncConn.Close()
+
+	start := time.Now()
+	reader := &payloadReader{block: block, remaining: opts.PayloadSize, deadline: start.Add(opts.Duration)}
+	throughput.Bytes, err = syncConn.Send(remotePath, 0o600, start, reader, nil)
+	throughput.finish(start)
+	throughput.Truncated = reader.truncated
+	if err != nil {
+		throughput.Err = fmt.Errorf("推送测试数据失败(已传输%d字节): %v", throughput.Bytes, err)
+	}
+	return throughput
+}
+
+// measurePhoneToHost 拉取设备上的测试文件测试云手机到宿主机的吞吐量，达到时长上限时中断连接
+func measurePhoneToHost(ctx context.Context, ipAddress, remotePath string, opts BandwidthOptions) *Throughput {
+	throughput := &Throughput{}
+	syncConn, err := OpenSync(ctx, ipAddress)
+	if err != nil {
+		throughput.Err = err
+		return throughput
+	}
+	defer syncConn.Close()
+
+	start := time.Now()
+	throughput.Bytes, err = syncConn.Recv(remotePath, &deadlineWriter{deadline: start.Add(opts.Duration)}, nil)
+	throughput.finish(start)
+	switch {
+	case errors.Is(err, errBandwidthDurationReached):
+		throughput.Truncated = true
+	case err != nil:
+		throughput.Err = fmt.Errorf("拉取测试数据失败(已传输%d字节): %v", throughput.Bytes, err)
+	}
+	return throughput
+}
+
+// createRemotePayload 在设备上生成指定大小的测试文件
+func createRemotePayload(ctx context.Context, ipAddress, remotePath string, opts BandwidthOptions) error {
+	const blockSize = 1024 * 1024
+	count := (opts.PayloadSize + blockSize - 1) / blockSize
+	command := fmt.Sprintf("dd if=/dev/zero of=%s bs=%d count=%d 2>/dev/null", shellQuote(remotePath), blockSize, count)
+
+	deviceAddr := deviceAddress(ipAddress)
+	connectDevice(ctx, deviceAddr)
+
+	// 生成时间与数据量相关，在准备超时之外预留一个传输时长
+	shellCtx, shellCancel := context.WithTimeout(ctx, opts.SetupTimeout+opts.Duration)
+	defer shellCancel()
+
+	_, stderr, exitCode, err := runADBShell(shellCtx, deviceAddr, command)
+	if err != nil {
+		return fmt.Errorf("生成设备侧测试文件失败: %v", err)
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("生成设备侧测试文件失败: 退出码=%d, %s", exitCode, stderr)
+	}
+	return nil
+}
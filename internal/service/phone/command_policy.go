@@ -0,0 +1,640 @@
+package phone
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/wumitech-com/mdcp_server_operator/internal/config"
+)
+
+const (
+	policyActionAllow     = "allow"
+	policyActionDeny      = "deny"
+	maxPolicyNestingDepth = 4 // eval / sh -c / su -c 嵌套解析的最大深度
+)
+
+var (
+	// envAssignmentRe 命令前的环境变量赋值，如 FOO=1 cmd
+	envAssignmentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+	// commandWrappers 透明包装命令，判定时跳过以取得真正执行的程序
+	commandWrappers = map[string]bool{
+		"busybox": true,
+		"toybox":  true,
+		"exec":    true,
+		"nohup":   true,
+		"command": true,
+		"env":     true,
+		"time":    true,
+	}
+
+	// nestedShells 可通过 -c 执行嵌套命令的shell，嵌套命令需递归判定
+	// 不带 -c 时从标准输入或脚本文件读取命令，内容无法判定，一律拒绝
+	nestedShells = map[string]bool{
+		"sh":   true,
+		"bash": true,
+		"mksh": true,
+		"ash":  true,
+		"dash": true,
+		"zsh":  true,
+	}
+
+	// opaqueExecutors 从标准输入等处拼出命令再执行的程序，实际命令无法判定，一律拒绝
+	opaqueExecutors = map[string]bool{
+		"xargs":  true,
+		"source": true,
+		".":      true,
+	}
+)
+
+// 会修改设备状态的类型化操作，不经过命令判定，由策略档案的 allowed_operations 逐项放行
+const (
+	OperationReboot           = "reboot"
+	OperationInstallPackage   = "install_package"
+	OperationUninstallPackage = "uninstall_package"
+	OperationPushFile         = "push_file"
+	OperationInstallADBKey    = "install_adb_key"
+)
+
+// knownOperations 策略档案可以放行的操作
+var knownOperations = map[string]bool{
+	OperationReboot:           true,
+	OperationInstallPackage:   true,
+	OperationUninstallPackage: true,
+	OperationPushFile:         true,
+	OperationInstallADBKey:    true,
+}
+
+// CommandDecision 命令策略判定结果
+type CommandDecision struct {
+	Allowed bool
+	Profile string // 生效的策略档案
+	Reason  string // 拒绝原因
+}
+
+// commandProfile 编译后的命令策略档案
+type commandProfile struct {
+	name                  string
+	defaultAllow          bool
+	allow                 []*regexp.Regexp
+	deny                  []*regexp.Regexp
+	argPattern            *regexp.Regexp
+	maxLength             int
+	allowOperators        bool
+	allowSubstitution     bool
+	allowInteractiveShell bool
+	operations            map[string]bool
+	writePaths            []*regexp.Regexp
+}
+
+// CommandPolicy 云手机命令执行策略，按调用方选择策略档案并在执行前判定命令
+type CommandPolicy struct {
+	enabled        bool
+	defaultProfile string
+	callers        map[string]string
+	profiles       map[string]*commandProfile
+}
+
+// NewCommandPolicy 根据配置编译命令策略
+func NewCommandPolicy(cfg config.CommandPolicyConfig) (*CommandPolicy, error) {
+	p := &CommandPolicy{
+		enabled:        cfg.Enabled,
+		defaultProfile: cfg.DefaultProfile,
+		callers:        cfg.Callers,
+		profiles:       make(map[string]*commandProfile, len(cfg.Profiles)),
+	}
+	if !p.enabled {
+		return p, nil
+	}
+
+	for name, profileCfg := range cfg.Profiles {
+		profile, err := compileCommandProfile(name, profileCfg)
+		if err != nil {
+			return nil, err
+		}
+		p.profiles[name] = profile
+	}
+
+	if _, ok := p.profiles[p.defaultProfile]; !ok {
+		return nil, fmt.Errorf("默认命令策略档案不存在: %s", p.defaultProfile)
+	}
+	for caller, profileName := range p.callers {
+		if _, ok := p.profiles[profileName]; !ok {
+			return nil, fmt.Errorf("调用方 %s 引用的命令策略档案不存在: %s", caller, profileName)
+		}
+	}
+
+	return p, nil
+}
+
+// compileCommandProfile 编译单个策略档案中的正则规则
+func compileCommandProfile(name string, cfg config.CommandProfileConfig) (*commandProfile, error) {
+	profile := &commandProfile{
+		name:                  name,
+		maxLength:             cfg.MaxCommandLength,
+		allowOperators:        cfg.AllowShellOperators,
+		allowSubstitution:     cfg.AllowSubstitution,
+		allowInteractiveShell: cfg.AllowInteractiveShell,
+		operations:            make(map[string]bool, len(cfg.AllowedOperations)),
+	}
+
+	for _, operation := range cfg.AllowedOperations {
+		if !knownOperations[operation] {
+			return nil, fmt.Errorf("命令策略档案 %s 的allowed_operations包含未知操作: %s", name, operation)
+		}
+		profile.operations[operation] = true
+	}
+
+	switch cfg.DefaultAction {
+	case policyActionAllow:
+		profile.defaultAllow = true
+	case policyActionDeny, "":
+		profile.defaultAllow = false
+	default:
+		return nil, fmt.Errorf("命令策略档案 %s 的default_action无效: %s", name, cfg.DefaultAction)
+	}
+
+	compileAll := func(patterns []string) ([]*regexp.Regexp, error) {
+		res := make([]*regexp.Regexp, 0, len(patterns))
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("命令策略档案 %s 的规则 %q 无效: %v", name, pattern, err)
+			}
+			res = append(res, re)
+		}
+		return res, nil
+	}
+
+	var err error
+	if profile.allow, err = compileAll(cfg.AllowPatterns); err != nil {
+		return nil, err
+	}
+	if profile.deny, err = compileAll(cfg.DenyPatterns); err != nil {
+		return nil, err
+	}
+	if profile.writePaths, err = compileAll(cfg.WritePathPatterns); err != nil {
+		return nil, err
+	}
+	if cfg.ArgPattern != "" {
+		if profile.argPattern, err = regexp.Compile(cfg.ArgPattern); err != nil {
+			return nil, fmt.Errorf("命令策略档案 %s 的arg_pattern无效: %v", name, err)
+		}
+	}
+
+	return profile, nil
+}
+
+// Enabled 是否启用了命令策略
+func (p *CommandPolicy) Enabled() bool {
+	return p.enabled
+}
+
+// profileFor 返回调用方对应的策略档案
+func (p *CommandPolicy) profileFor(caller string) *commandProfile {
+	if name, ok := p.callers[caller]; ok && caller != "" {
+		return p.profiles[name]
+	}
+	return p.profiles[p.defaultProfile]
+}
+
+// Evaluate 判定调用方是否可以执行该命令
+func (p *CommandPolicy) Evaluate(caller, command string) CommandDecision {
+	if !p.enabled {
+		return CommandDecision{Allowed: true}
+	}
+
+	profile := p.profileFor(caller)
+	if profile.maxLength > 0 && len(command) > profile.maxLength {
+		return CommandDecision{Profile: profile.name, Reason: fmt.Sprintf("命令长度超过限制(%d)", profile.maxLength)}
+	}
+
+	if reason := profile.evaluate(command, 0); reason != "" {
+		return CommandDecision{Profile: profile.name, Reason: reason}
+	}
+	return CommandDecision{Allowed: true, Profile: profile.name}
+}
+
+// EvaluateInteractiveShell 判定调用方是否可以打开交互式Shell
+func (p *CommandPolicy) EvaluateInteractiveShell(caller string) CommandDecision {
+	if !p.enabled {
+		return CommandDecision{Allowed: true}
+	}
+
+	profile := p.profileFor(caller)
+	if !profile.allowInteractiveShell {
+		return CommandDecision{Profile: profile.name, Reason: "策略档案不允许交互式Shell"}
+	}
+	return CommandDecision{Allowed: true, Profile: profile.name}
+}
+
+// EvaluateOperation 判定调用方是否可以执行重启、安装、推送文件等设备变更操作
+func (p *CommandPolicy) EvaluateOperation(caller, operation string) CommandDecision {
+	if !p.enabled {
+		return CommandDecision{Allowed: true}
+	}
+
+	profile := p.profileFor(caller)
+	if !profile.operations[operation] {
+		return CommandDecision{Profile: profile.name, Reason: fmt.Sprintf("策略档案不允许操作: %s", operation)}
+	}
+	return CommandDecision{Allowed: true, Profile: profile.name}
+}
+
+// evaluate 判定命令，返回拒绝原因，允许时返回空串
+func (prof *commandProfile) evaluate(command string, depth int) string {
+	if depth > maxPolicyNestingDepth {
+		return "命令嵌套层级过深"
+	}
+
+	parsed, err := parseShellCommand(command)
+	if err != nil {
+		return err.Error()
+	}
+	if parsed.hasSubstitution && !prof.allowSubstitution {
+		return "不允许使用命令替换"
+	}
+	if parsed.hasOperator && !prof.allowOperators {
+		return "不允许使用管道、命令串联或重定向"
+	}
+	if len(parsed.segments) == 0 && len(parsed.writeTargets) == 0 {
+		return "命令为空"
+	}
+
+	// 输出重定向等同于写文件，目标路径单独判定，不随子命令的允许规则放行
+	for _, target := range parsed.writeTargets {
+		if reason := prof.checkWriteTarget(target); reason != "" {
+			return reason
+		}
+	}
+
+	for _, args := range parsed.segments {
+		args = normalizeCommandArgs(args)
+		if len(args) == 0 {
+			continue
+		}
+		line := strings.Join(args, " ")
+
+		for _, re := range prof.deny {
+			if re.MatchString(line) {
+				return fmt.Sprintf("命中拒绝规则 %q: %s", re.String(), line)
+			}
+		}
+
+		if opaqueExecutors[args[0]] {
+			return fmt.Sprintf("不允许通过 %s 执行命令", args[0])
+		}
+
+		// eval / sh -c / su -c 的实际命令递归判定
+		if inner, nested := nestedCommand(args); nested {
+			if inner == "" {
+				return fmt.Sprintf("无法判定 %s 实际执行的命令", args[0])
+			}
+			if reason := prof.evaluate(inner, depth+1); reason != "" {
+				return reason
+			}
+			continue
+		}
+
+		if prof.argPattern != nil {
+			for _, arg := range args[1:] {
+				if !prof.argPattern.MatchString(arg) {
+					return fmt.Sprintf("参数不合法: %q", arg)
+				}
+			}
+		}
+
+		allowed := prof.defaultAllow
+		for _, re := range prof.allow {
+			if re.MatchString(line) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Sprintf("未命中允许规则: %s", line)
+		}
+	}
+
+	return ""
+}
+
+// checkWriteTarget 判定输出重定向的目标路径，返回拒绝原因，允许时返回空串
+func (prof *commandProfile) checkWriteTarget(target string) string {
+	if len(prof.writePaths) == 0 {
+		return fmt.Sprintf("不允许输出重定向: %s", target)
+	}
+	// 变量、通配符和相对路径的实际目标在设备上才能确定，无法判定
+	if strings.ContainsAny(target, "$`*?[~") || !path.IsAbs(target) {
+		return fmt.Sprintf("输出重定向目标必须为不含变量和通配符的绝对路径: %s", target)
+	}
+
+	cleaned := path.Clean(target)
+	for _, re := range prof.writePaths {
+		if re.MatchString(cleaned) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("输出重定向目标不在允许路径内: %s", cleaned)
+}
+
+// normalizeCommandArgs 去掉前置环境变量赋值和透明包装命令，并将程序路径规整为程序名
+func normalizeCommandArgs(args []string) []string {
+	for len(args) > 0 {
+		if envAssignmentRe.MatchString(args[0]) {
+			args = args[1:]
+			continue
+		}
+		program := path.Base(args[0])
+		if commandWrappers[program] {
+			args = args[1:]
+			continue
+		}
+		normalized := append([]string{program}, args[1:]...)
+		return normalized
+	}
+	return nil
+}
+
+// nestedCommand 提取 eval、sh -c、su -c 等形式实际执行的命令
+// nested=true 且 inner 为空表示命令来自标准输入或脚本文件，无法判定
+func nestedCommand(args []string) (inner string, nested bool) {
+	switch {
+	case args[0] == "eval":
+		return strings.Join(args[1:], " "), true
+	case args[0] == "su":
+		return suCommand(args[1:]), true
+	case nestedShells[args[0]]:
+		return shellCommand(args[1:]), true
+	}
+	return "", false
+}
+
+// shellCommand 解析 sh 的参数，选项组中含 c（如 -c、-ec、-xc）时返回第一个操作数
+func shellCommand(args []string) string {
+	hasC := false
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			break
+		}
+		if strings.HasPrefix(arg, "--") {
+			continue // bash 的长选项，如 --norc
+		}
+		if arg[0] == '-' && strings.ContainsRune(arg[1:], 'c') {
+			hasC = true
+		}
+		// -o / -O 后跟选项名
+		if strings.ContainsAny(arg[1:], "oO") {
+			i++
+		}
+	}
+
+	if !hasC || i >= len(args) {
+		return ""
+	}
+	return args[i]
+}
+
+// suCommand 解析 su 的参数，返回 -c 指定的命令或用户名之后的命令
+// 形如 su [-c CMD] [USER [COMMAND...]]，没有命令时为交互式，返回空串
+func suCommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-c" || arg == "--command":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+		case strings.HasPrefix(arg, "--command="):
+			return strings.TrimPrefix(arg, "--command=")
+		case arg == "-s" || arg == "--shell" || arg == "-u" || arg == "-g" || arg == "-G" || arg == "-Z" || arg == "--context":
+			i++ // 带参数的选项
+		case len(arg) > 1 && arg[0] == '-' && !strings.HasPrefix(arg, "--"):
+			if idx := strings.IndexRune(arg, 'c'); idx > 0 {
+				// 组合选项，如 -lc CMD 或 -cCMD
+				if idx < len(arg)-1 {
+					return arg[idx+1:]
+				}
+				if i+1 < len(args) {
+					return args[i+1]
+				}
+				return ""
+			}
+		case strings.HasPrefix(arg, "-"):
+			// 其余不带参数的长选项，如 --mount-master
+		default:
+			// 第一个操作数为用户，其后为要执行的命令
+			return strings.Join(args[i+1:], " ")
+		}
+	}
+	return ""
+}
+
+// redirectKind 解析中等待目标的重定向类型
+type redirectKind int
+
+const (
+	redirectNone  redirectKind = iota
+	redirectWrite              // > >> >| &> <>，目标为写入的文件
+	redirectDup                // >&，目标为文件描述符时只是复制，否则写入文件
+)
+
+// parsedCommand 按shell规则拆分后的命令
+type parsedCommand struct {
+	segments        [][]string // 按 ; & | 换行 () 拆分出的子命令参数列表，不含重定向目标
+	writeTargets    []string   // 输出重定向的目标文件
+	hasOperator     bool       // 是否使用了控制符或重定向
+	hasSubstitution bool       // 是否使用了命令替换
+}
+
+// isFileDescriptor 是否为重定向中的文件描述符编号
+func isFileDescriptor(word string) bool {
+	if word == "" {
+		return false
+	}
+	for _, c := range word {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseShellCommand 按shell引号规则拆分命令
+// 输入重定向的目标仍作为当前子命令的参数参与判定，输出重定向的目标单独返回
+func parseShellCommand(command string) (*parsedCommand, error) {
+	var (
+		parsed        parsedCommand
+		args          []string
+		token         strings.Builder
+		tokenStarted  bool
+		inSingle      bool
+		inDouble      bool
+		redirect      redirectKind
+		missingTarget bool
+	)
+
+	flushToken := func() {
+		if !tokenStarted {
+			return
+		}
+		word := token.String()
+		token.Reset()
+		tokenStarted = false
+
+		switch redirect {
+		case redirectWrite:
+			parsed.writeTargets = append(parsed.writeTargets, word)
+		case redirectDup:
+			if word != "-" && !isFileDescriptor(word) {
+				parsed.writeTargets = append(parsed.writeTargets, word)
+			}
+		default:
+			args = append(args, word)
+		}
+		redirect = redirectNone
+	}
+	flushSegment := func() {
+		flushToken()
+		if redirect != redirectNone {
+			missingTarget = true
+			redirect = redirectNone
+		}
+		if len(args) > 0 {
+			parsed.segments = append(parsed.segments, args)
+			args = nil
+		}
+	}
+	startRedirect := func(kind redirectKind) {
+		parsed.hasOperator = true
+		// 紧贴在重定向符前的数字是文件描述符（如 2>），不是参数
+		if tokenStarted && redirect == redirectNone && isFileDescriptor(token.String()) {
+			token.Reset()
+			tokenStarted = false
+		}
+		flushToken()
+		if redirect != redirectNone {
+			missingTarget = true
+		}
+		redirect = kind
+	}
+
+	runes := []rune(command)
+	next := func(i int, c rune) bool {
+		return i+1 < len(runes) && runes[i+1] == c
+	}
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+
+		switch {
+		case inSingle:
+			if c == '\'' {
+				inSingle = false
+			} else {
+				token.WriteRune(c)
+			}
+
+		case inDouble:
+			switch {
+			case c == '"':
+				inDouble = false
+			case c == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]):
+				i++
+				token.WriteRune(runes[i])
+			case c == '`', c == '$' && next(i, '('):
+				parsed.hasSubstitution = true
+				token.WriteRune(c)
+			default:
+				token.WriteRune(c)
+			}
+
+		default:
+			switch c {
+			case '\\':
+				tokenStarted = true
+				if i+1 < len(runes) {
+					i++
+					token.WriteRune(runes[i])
+				}
+			case '\'':
+				tokenStarted = true
+				inSingle = true
+			case '"':
+				tokenStarted = true
+				inDouble = true
+			case ' ', '\t':
+				flushToken()
+			case '&':
+				if next(i, '>') {
+					// &> / &>> 同时重定向标准输出和标准错误
+					i++
+					if next(i, '>') {
+						i++
+					}
+					startRedirect(redirectWrite)
+					continue
+				}
+				parsed.hasOperator = true
+				flushSegment()
+			case ';', '|', '\n', '(', ')':
+				parsed.hasOperator = true
+				flushSegment()
+			case '>':
+				kind := redirectWrite
+				switch {
+				case next(i, '>'), next(i, '|'):
+					i++
+				case next(i, '&'):
+					i++
+					kind = redirectDup
+				}
+				startRedirect(kind)
+			case '<':
+				if next(i, '>') {
+					// <> 以读写方式打开目标，按写入判定
+					i++
+					startRedirect(redirectWrite)
+					continue
+				}
+				// 输入重定向（含 << 和 <<<）的目标作为参数判定
+				parsed.hasOperator = true
+				flushToken()
+				for next(i, '<') {
+					i++
+				}
+			case '`':
+				parsed.hasSubstitution = true
+				tokenStarted = true
+				token.WriteRune(c)
+			case '$':
+				if next(i, '(') {
+					parsed.hasSubstitution = true
+				}
+				tokenStarted = true
+				token.WriteRune(c)
+			default:
+				tokenStarted = true
+				token.WriteRune(c)
+			}
+		}
+	}
+
+	if inSingle || inDouble {
+		return nil, fmt.Errorf("命令引号未闭合")
+	}
+	flushSegment()
+	if missingTarget {
+		return nil, fmt.Errorf("重定向缺少目标")
+	}
+
+	return &parsed, nil
+}
@@ -0,0 +1,220 @@
+package phone
+
+import (
+	"testing"
+
+	"github.com/wumitech-com/mdcp_server_operator/internal/config"
+)
+
+// newTestCommandPolicy 构造与 configs 中默认档案思路一致的策略：
+// strict 为默认拒绝的允许列表；denylist 为默认放行的拒绝列表，用于验证嵌套命令的识别
+func newTestCommandPolicy(t *testing.T) *CommandPolicy {
+	t.Helper()
+	policy, err := NewCommandPolicy(config.CommandPolicyConfig{
+		Enabled:        true,
+		DefaultProfile: "strict",
+		Callers:        map[string]string{"ops": "denylist"},
+		Profiles: map[string]config.CommandProfileConfig{
+			"strict": {
+				DefaultAction: "deny",
+				ArgPattern:    `^[^\x00-\x08\x0b-\x1f\x7f]*$`,
+				AllowPatterns: []string{
+					`^getprop( \S+)?$`,
+					`^(ls|cat|echo)( |$)`,
+				},
+				AllowShellOperators: true,
+			},
+			"denylist": {
+				DefaultAction:       "allow",
+				AllowShellOperators: true,
+				AllowedOperations:   []string{OperationReboot, OperationPushFile},
+				WritePathPatterns:   []string{`^/dev/null$`, `^/sdcard/`},
+				DenyPatterns: []string{
+					`^(reboot|shutdown|poweroff)\b`,
+					`^rm\s.*-[a-zA-Z]*[rR]`,
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCommandPolicy: %v", err)
+	}
+	return policy
+}
+
+func TestCommandPolicyEvaluate(t *testing.T) {
+	policy := newTestCommandPolicy(t)
+
+	tests := []struct {
+		name    string
+		caller  string
+		command string
+		allowed bool
+	}{
+		{"allowlisted", "", "getprop ro.serialno", true},
+		{"allowlisted with pipe", "", "ls /sdcard | cat", true},
+		{"not allowlisted", "", "pm clear com.example", false},
+		{"unknown caller uses default", "mdcp_support", "pm clear com.example", false},
+		{"absolute path normalized", "", "/system/bin/getprop ro.serialno", true},
+		{"wrapper skipped", "", "toybox getprop", true},
+		{"env assignment skipped", "", "FOO=1 getprop", true},
+		{"unclosed quote", "", "getprop 'ro.serialno", false},
+		{"control char in arg", "", "cat \"a\x01b\"", false},
+
+		{"denylist plain", "ops", "reboot", false},
+		{"denylist allowed", "ops", "pm list packages", true},
+		{"sh -c nested", "ops", "sh -c reboot", false},
+		{"sh -ec combined flags", "ops", "sh -ec reboot", false},
+		{"sh -xc combined flags", "ops", "sh -xc 'reboot'", false},
+		{"sh -e -c split flags", "ops", "sh -e -c reboot", false},
+		{"sh -o option before -c", "ops", "sh -o pipefail -c reboot", false},
+		{"bash -- after -c", "ops", "bash -c -- reboot", false},
+		{"sh -c allowed inner", "ops", "sh -c 'pm list packages'", true},
+		{"sh -c nested twice", "ops", "sh -c \"sh -c reboot\"", false},
+		{"busybox sh -c", "ops", "busybox sh -c reboot", false},
+		{"sh absolute path", "ops", "/system/bin/sh -c reboot", false},
+		{"sh fed from pipe", "ops", "echo reboot | sh", false},
+		{"sh script file", "ops", "sh /data/local/tmp/x.sh", false},
+		{"sh -s from stdin", "ops", "echo reboot | sh -s", false},
+		{"eval", "ops", "eval reboot", false},
+		{"eval quoted", "ops", "eval 'rm -rf /data'", false},
+		{"eval allowed inner", "ops", "eval pm list packages", true},
+		{"xargs", "ops", "echo reboot | xargs", false},
+		{"toybox xargs", "ops", "toybox xargs reboot", false},
+		{"source", "ops", "source /data/local/tmp/x.sh", false},
+		{"dot source", "ops", ". /data/local/tmp/x.sh", false},
+		{"su -c", "ops", "su -c reboot", false},
+		{"su combined -lc", "ops", "su -lc reboot", false},
+		{"su -cCMD", "ops", "su -creboot", false},
+		{"su --command=", "ops", "su --command=reboot", false},
+		{"su user command", "ops", "su 0 reboot", false},
+		{"su user sh -c", "ops", "su root sh -c reboot", false},
+		{"su interactive", "ops", "su", false},
+		{"su allowed inner", "ops", "su 0 pm list packages", true},
+		{"nested too deep", "ops", "eval eval eval eval eval eval id", false},
+		{"chained after allowed", "ops", "id; reboot", false},
+		{"subshell", "ops", "(reboot)", false},
+		{"substitution", "ops", "echo $(id)", false},
+
+		{"input redirect", "", "cat < /sdcard/a.txt", true},
+		{"output redirect without write paths", "", "cat /dev/zero > /sdcard/x", false},
+		{"redirect to block device", "ops", "cat /dev/zero > /dev/block/by-name/userdata", false},
+		{"redirect into system", "ops", "cat x > /system/bin/y", false},
+		{"append redirect", "ops", "echo a >> /system/etc/hosts", false},
+		{"clobber redirect", "ops", "echo a >| /system/etc/hosts", false},
+		{"redirect allowed path", "ops", "echo a > /sdcard/x", true},
+		{"redirect quoted allowed path", "ops", "echo a >'/sdcard/x y'", true},
+		{"stderr to dev null", "ops", "ls /sdcard 2>/dev/null", true},
+		{"fd dup", "ops", "ls /sdcard 2>&1", true},
+		{"fd number as argument", "ops", "echo 2 > /sdcard/x", true},
+		{"dup to file", "ops", "echo a >&/system/x", false},
+		{"ampersand redirect", "ops", "ls &>/system/x", false},
+		{"read write open", "ops", "cat <> /system/x", false},
+		{"redirect traversal", "ops", "echo a > /sdcard/../system/x", false},
+		{"relative target", "ops", "echo a > x", false},
+		{"variable target", "ops", "echo a > /sdcard/$X", false},
+		{"glob target", "ops", "echo a > /sdcard/*", false},
+		{"redirect without target", "ops", "echo a >", false},
+		{"redirect before operator", "ops", "echo a > ; ls", false},
+		{"bare redirect", "ops", "> /system/x", false},
+		{"redirect in nested sh", "ops", "sh -c 'echo a > /system/x'", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := policy.Evaluate(tt.caller, tt.command)
+			if decision.Allowed != tt.allowed {
+				t.Errorf("Evaluate(%q, %q) allowed=%v, want %v (profile=%s, reason=%s)",
+					tt.caller, tt.command, decision.Allowed, tt.allowed, decision.Profile, decision.Reason)
+			}
+		})
+	}
+}
+
+func TestCommandPolicyEvaluateOperation(t *testing.T) {
+	policy := newTestCommandPolicy(t)
+
+	tests := []struct {
+		caller    string
+		operation string
+		allowed   bool
+	}{
+		{"", OperationReboot, false},
+		{"", OperationInstallPackage, false},
+		{"ops", OperationReboot, true},
+		{"ops", OperationPushFile, true},
+		{"ops", OperationInstallADBKey, false},
+		{"mdcp_support", OperationReboot, false},
+	}
+
+	for _, tt := range tests {
+		decision := policy.EvaluateOperation(tt.caller, tt.operation)
+		if decision.Allowed != tt.allowed {
+			t.Errorf("EvaluateOperation(%q, %q) allowed=%v, want %v (profile=%s, reason=%s)",
+				tt.caller, tt.operation, decision.Allowed, tt.allowed, decision.Profile, decision.Reason)
+		}
+	}
+}
+
+func TestNewCommandPolicyUnknownOperation(t *testing.T) {
+	_, err := NewCommandPolicy(config.CommandPolicyConfig{
+		Enabled:        true,
+		DefaultProfile: "default",
+		Profiles: map[string]config.CommandProfileConfig{
+			"default": {AllowedOperations: []string{"format_disk"}},
+		},
+	})
+	if err == nil {
+		t.Fatal("NewCommandPolicy accepted an unknown operation")
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-c", "id"}, "id"},
+		{[]string{"-ec", "id"}, "id"},
+		{[]string{"-ce", "id"}, "id"},
+		{[]string{"-e", "-c", "id"}, "id"},
+		{[]string{"-o", "pipefail", "-c", "id"}, "id"},
+		{[]string{"--norc", "-c", "id"}, "id"},
+		{[]string{"-c", "--", "id"}, "id"},
+		{[]string{"-c"}, ""},
+		{[]string{"-e"}, ""},
+		{[]string{"script.sh"}, ""},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := shellCommand(tt.args); got != tt.want {
+			t.Errorf("shellCommand(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestSuCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-c", "id"}, "id"},
+		{[]string{"-lc", "id"}, "id"},
+		{[]string{"-cid"}, "id"},
+		{[]string{"--command", "id"}, "id"},
+		{[]string{"--command=id"}, "id"},
+		{[]string{"--mount-master", "-c", "id"}, "id"},
+		{[]string{"-s", "/system/bin/sh", "-c", "id"}, "id"},
+		{[]string{"0", "id", "-u"}, "id -u"},
+		{[]string{"root"}, ""},
+		{[]string{"-l"}, ""},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := suCommand(tt.args); got != tt.want {
+			t.Errorf("suCommand(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
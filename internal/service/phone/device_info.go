@@ -0,0 +1,191 @@
+package phone
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deviceInfoScript 一次 adb shell 收集全部设备信息，各段以 @@名称 分隔
+const deviceInfoScript = `echo @@serial; getprop ro.serialno
+echo @@model; getprop ro.product.model
+echo @@manufacturer; getprop ro.product.manufacturer
+echo @@brand; getprop ro.product.brand
+echo @@android_version; getprop ro.build.version.release
+echo @@sdk; getprop ro.build.version.sdk
+echo @@fingerprint; getprop ro.build.fingerprint
+echo @@abi; getprop ro.product.cpu.abi
+echo @@abilist; getprop ro.product.cpu.abilist
+echo @@net; ` + listInterfacesScript + `
+echo @@imei; service call iphonesubinfo 1 2>/dev/null
+echo @@android_id; settings get secure android_id 2>/dev/null
+echo @@meminfo; cat /proc/meminfo
+echo @@storage; df -k /data 2>/dev/null
+echo @@uptime; cat /proc/uptime`
+
+// parcelTextRe service call 返回的 Parcel 中每行引号内的可见字符
+var parcelTextRe = regexp.MustCompile(`'([^']*)'`)
+
+// DeviceInfo 云手机软硬件信息
+type DeviceInfo struct {
+	SerialNumber          string
+	Model                 string
+	Manufacturer          string
+	Brand                 string
+	AndroidVersion        string
+	SDKVersion            int32
+	BuildFingerprint      string
+	IMEI                  string // 系统限制读取时为空
+	AndroidID             string
+	CPUABI                string
+	CPUABIList            []string
+	MemTotalBytes         int64
+	MemAvailableBytes     int64
+	StorageTotalBytes     int64
+	StorageAvailableBytes int64
+	UptimeSeconds         float64
+	Interfaces            []InterfaceMAC
+}
+
+// GetDeviceInfo 通过一次ADB连接获取设备的软硬件信息
+func GetDeviceInfo(ctx context.Context, ipAddress string, timeout int32) (*DeviceInfo, error) {
+	if timeout <= 0 {
+		timeout = defaultADBTimeout
+	}
+
+	deviceAddr := deviceAddress(ipAddress)
+	connectDevice(ctx, deviceAddr)
+
+	shellCtx, shellCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer shellCancel()
+
+	stdout, stderr, exitCode, err := runADBShell(shellCtx, deviceAddr, deviceInfoScript)
+	if err != nil {
+		return nil, fmt.Errorf("获取设备信息失败: %v", err)
+	}
+
+	sections := splitScriptSections(stdout)
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("获取设备信息失败: ExitCode=%d, %s", exitCode, strings.TrimSpace(stderr))
+	}
+
+	info := &DeviceInfo{
+		SerialNumber:     sections["serial"],
+		Model:            sections["model"],
+		Manufacturer:     sections["manufacturer"],
+		Brand:            sections["brand"],
+		AndroidVersion:   sections["android_version"],
+		BuildFingerprint: sections["fingerprint"],
+		CPUABI:           sections["abi"],
+		IMEI:             parseParcelIMEI(sections["imei"]),
+		Interfaces:       parseInterfaceMACs(sections["net"]),
+	}
+	if info.SerialNumber == "" {
+		return nil, fmt.Errorf("获取到的SN码无效")
+	}
+
+	if sdk, err := strconv.ParseInt(sections["sdk"], 10, 32); err == nil {
+		info.SDKVersion = int32(sdk)
+	}
+	if abiList := sections["abilist"]; abiList != "" {
+		info.CPUABIList = strings.Split(abiList, ",")
+	}
+	if androidID := sections["android_id"]; androidID != "null" {
+		info.AndroidID = androidID
+	}
+	info.MemTotalBytes, info.MemAvailableBytes = parseMeminfo(sections["meminfo"])
+	info.StorageTotalBytes, info.StorageAvailableBytes = parseDfData(sections["storage"])
+	if uptime := strings.Fields(sections["uptime"]); len(uptime) > 0 {
+		info.UptimeSeconds, _ = strconv.ParseFloat(uptime[0], 64)
+	}
+
+	return info, nil
+}
+
+// splitScriptSections 按 @@名称 标记拆分脚本输出
+func splitScriptSections(output string) map[string]string {
+	sections := make(map[string]string)
+	var (
+		name string
+		body []string
+	)
+	flush := func() {
+		if name != "" {
+			sections[name] = strings.TrimSpace(strings.Join(body, "\n"))
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "@@") {
+			flush()
+			name, body = strings.TrimPrefix(line, "@@"), nil
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+
+	return sections
+}
+
+// parseParcelIMEI 从 service call iphonesubinfo 的 Parcel 输出中提取IMEI
+func parseParcelIMEI(output string) string {
+	var b strings.Builder
+	for _, m := range parcelTextRe.FindAllStringSubmatch(output, -1) {
+		for _, c := range m[1] {
+			if c >= '0' && c <= '9' {
+				b.WriteRune(c)
+			}
+		}
+	}
+
+	imei := b.String()
+	if len(imei) < 14 || len(imei) > 16 {
+		return ""
+	}
+	return imei
+}
+
+// parseMeminfo 解析 /proc/meminfo 中的总内存和可用内存（字节）
+func parseMeminfo(output string) (int64, int64) {
+	var total, available int64
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = kb * 1024
+		case "MemAvailable:":
+			available = kb * 1024
+		}
+	}
+	return total, available
+}
+
+// parseDfData 解析 df -k 输出中的总容量和可用容量（字节）
+func parseDfData(output string) (int64, int64) {
+	lines := strings.Split(output, "\n")
+	if len(lines) < 2 {
+		return 0, 0
+	}
+	// Filesystem 1K-blocks Used Available Use% Mounted on
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return 0, 0
+	}
+	total, _ := strconv.ParseInt(fields[1], 10, 64)
+	available, _ := strconv.ParseInt(fields[3], 10, 64)
+	return total * 1024, available * 1024
+}
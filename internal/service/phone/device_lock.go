@@ -0,0 +1,189 @@
+package phone
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/wumitech-com/mdcp_server_operator/internal/config"
+)
+
+const (
+	defaultLockQueueDepth  = 16 // 默认每台设备的最大排队数
+	defaultLockWaitTimeout = 30 // 默认等待设备锁超时时间（秒）
+)
+
+var (
+	// ErrDeviceBusy 设备排队已满
+	ErrDeviceBusy = errors.New("设备繁忙，排队已满")
+	// ErrLockWaitTimeout 等待设备锁超时
+	ErrLockWaitTimeout = errors.New("等待设备锁超时")
+)
+
+// LockMode 设备锁模式
+type LockMode int
+
+const (
+	LockShared    LockMode = iota // 共享锁，只读操作可并发
+	LockExclusive                 // 独占锁，会修改设备状态的操作互斥执行
+)
+
+// lockWaiter 排队中的加锁请求
+type lockWaiter struct {
+	mode    LockMode
+	ready   chan struct{}
+	granted bool
+}
+
+// deviceLock 单台设备的锁状态
+type deviceLock struct {
+	readers int
+	writer  bool
+	waiters []*lockWaiter
+}
+
+// compatible 判断当前状态下能否授予指定模式的锁
+func (l *deviceLock) compatible(mode LockMode) bool {
+	if mode == LockExclusive {
+		return !l.writer && l.readers == 0
+	}
+	return !l.writer
+}
+
+// grant 授予锁
+func (l *deviceLock) grant(mode LockMode) {
+	if mode == LockExclusive {
+		l.writer = true
+	} else {
+		l.readers++
+	}
+}
+
+// dispatch 按先进先出顺序唤醒可以获得锁的等待者，队首不兼容时后面的请求继续等待
+func (l *deviceLock) dispatch() {
+	for len(l.waiters) > 0 {
+		w := l.waiters[0]
+		if !l.compatible(w.mode) {
+			return
+		}
+		l.grant(w.mode)
+		w.granted = true
+		close(w.ready)
+		l.waiters = l.waiters[1:]
+	}
+}
+
+// DeviceLockManager 按设备IP串行化操作，独占操作互斥，共享操作可并发
+type DeviceLockManager struct {
+	enabled       bool
+	maxQueueDepth int
+	waitTimeout   time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*deviceLock
+}
+
+// NewDeviceLockManager 创建设备锁管理器
+func NewDeviceLockManager(cfg config.DeviceLockConfig) *DeviceLockManager {
+	maxQueueDepth := cfg.MaxQueueDepth
+	if maxQueueDepth <= 0 {
+		maxQueueDepth = defaultLockQueueDepth
+	}
+	waitTimeout := cfg.WaitTimeout
+	if waitTimeout <= 0 {
+		waitTimeout = defaultLockWaitTimeout
+	}
+
+	return &DeviceLockManager{
+		enabled:       cfg.Enabled,
+		maxQueueDepth: maxQueueDepth,
+		waitTimeout:   time.Duration(waitTimeout) * time.Second,
+		locks:         make(map[string]*deviceLock),
+	}
+}
+
+// Acquire 获取设备锁，返回释放函数
+// 排队已满返回 ErrDeviceBusy，等待超时返回 ErrLockWaitTimeout，ctx 取消时返回 ctx 的错误
+func (m *DeviceLockManager) Acquire(ctx context.Context, ipAddress string, mode LockMode) (func(), error) {
+	if !m.enabled {
+		return func() {}, nil
+	}
+
+	m.mu.Lock()
+	l, ok := m.locks[ipAddress]
+	if !ok {
+		l = &deviceLock{}
+		m.locks[ipAddress] = l
+	}
+
+	// 有人排队时不插队，保证独占请求不会被持续到来的共享请求饿死
+	if len(l.waiters) == 0 && l.compatible(mode) {
+		l.grant(mode)
+		m.mu.Unlock()
+		return m.releaser(ipAddress, l, mode), nil
+	}
+	if len(l.waiters) >= m.maxQueueDepth {
+		m.mu.Unlock()
+		return nil, ErrDeviceBusy
+	}
+
+	w := &lockWaiter{mode: mode, ready: make(chan struct{})}
+	l.waiters = append(l.waiters, w)
+	m.mu.Unlock()
+
+	timer := time.NewTimer(m.waitTimeout)
+	defer timer.Stop()
+
+	var waitErr error
+	select {
+	case <-w.ready:
+		return m.releaser(ipAddress, l, mode), nil
+	case <-timer.C:
+		waitErr = ErrLockWaitTimeout
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if w.granted {
+		// 超时与授予同时发生，已拿到锁则按成功处理
+		return m.releaser(ipAddress, l, mode), nil
+	}
+	for i, waiter := range l.waiters {
+		if waiter == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			break
+		}
+	}
+	// 移除的可能是阻塞后续共享请求的独占请求
+	l.dispatch()
+	m.cleanup(ipAddress, l)
+	return nil, waitErr
+}
+
+// releaser 返回只生效一次的释放函数
+func (m *DeviceLockManager) releaser(ipAddress string, l *deviceLock, mode LockMode) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if mode == LockExclusive {
+				l.writer = false
+			} else {
+				l.readers--
+			}
+			l.dispatch()
+			m.cleanup(ipAddress, l)
+		})
+	}
+}
+
+// cleanup 设备空闲时删除锁状态，调用方需持有 m.mu
+func (m *DeviceLockManager) cleanup(ipAddress string, l *deviceLock) {
+	if !l.writer && l.readers == 0 && len(l.waiters) == 0 {
+		delete(m.locks, ipAddress)
+	}
+}
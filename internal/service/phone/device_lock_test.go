@@ -0,0 +1,247 @@
+package phone
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wumitech-com/mdcp_server_operator/internal/config"
+)
+
+const testLockIP = "10.0.0.1"
+
+// lockResult 异步加锁的结果
+type lockResult struct {
+	release func()
+	err     error
+}
+
+func newTestLockManager(maxQueueDepth int, waitTimeout time.Duration) *DeviceLockManager {
+	m := NewDeviceLockManager(config.DeviceLockConfig{Enabled: true, MaxQueueDepth: maxQueueDepth})
+	m.waitTimeout = waitTimeout
+	return m
+}
+
+// acquireAsync 在后台加锁，并等待请求进入队列或直接获得锁
+func acquireAsync(t *testing.T, m *DeviceLockManager, ctx context.Context, mode LockMode, wantQueued int) <-chan lockResult {
+	t.Helper()
+	ch := make(chan lockResult, 1)
+	go func() {
+		release, err := m.Acquire(ctx, testLockIP, mode)
+		ch <- lockResult{release, err}
+	}()
+	waitQueued(t, m, wantQueued)
+	return ch
+}
+
+// waitQueued 等待设备的排队数达到 n
+func waitQueued(t *testing.T, m *DeviceLockManager, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.Lock()
+		queued := 0
+		if l, ok := m.locks[testLockIP]; ok {
+			queued = len(l.waiters)
+		}
+		m.mu.Unlock()
+		if queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queued = %d, want %d", queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// mustGrant 断言请求已获得锁并返回释放函数
+func mustGrant(t *testing.T, ch <-chan lockResult) func() {
+	t.Helper()
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			t.Fatalf("Acquire: %v", r.err)
+		}
+		return r.release
+	case <-time.After(time.Second):
+		t.Fatal("lock not granted")
+		return nil
+	}
+}
+
+// mustWait 断言请求仍在等待
+func mustWait(t *testing.T, ch <-chan lockResult) {
+	t.Helper()
+	select {
+	case r := <-ch:
+		t.Fatalf("lock granted while it should wait (err=%v)", r.err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestDeviceLockSharedExclusive(t *testing.T) {
+	tests := []struct {
+		name    string
+		held    LockMode
+		request LockMode
+		granted bool // 持有 held 时 request 能否立即获得
+	}{
+		{"shared with shared", LockShared, LockShared, true},
+		{"shared with exclusive", LockShared, LockExclusive, false},
+		{"exclusive with shared", LockExclusive, LockShared, false},
+		{"exclusive with exclusive", LockExclusive, LockExclusive, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestLockManager(4, time.Second)
+			release, err := m.Acquire(context.Background(), testLockIP, tt.held)
+			if err != nil {
+				t.Fatalf("Acquire: %v", err)
+			}
+
+			queued := 1
+			if tt.granted {
+				queued = 0
+			}
+			ch := acquireAsync(t, m, context.Background(), tt.request, queued)
+			if tt.granted {
+				mustGrant(t, ch)()
+				release()
+			} else {
+				mustWait(t, ch)
+				release()
+				mustGrant(t, ch)()
+			}
+
+			if len(m.locks) != 0 {
+				t.Errorf("lock state not cleaned up: %d devices", len(m.locks))
+			}
+		})
+	}
+}
+
+func TestDeviceLockFIFO(t *testing.T) {
+	m := newTestLockManager(4, time.Second)
+	releaseShared, err := m.Acquire(context.Background(), testLockIP, LockShared)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	// 独占请求排队后，后到的共享请求即使与当前持有者兼容也不插队
+	exclusive := acquireAsync(t, m, context.Background(), LockExclusive, 1)
+	shared := acquireAsync(t, m, context.Background(), LockShared, 2)
+	mustWait(t, exclusive)
+	mustWait(t, shared)
+
+	releaseShared()
+	releaseExclusive := mustGrant(t, exclusive)
+	mustWait(t, shared)
+
+	releaseExclusive()
+	mustGrant(t, shared)()
+
+	if len(m.locks) != 0 {
+		t.Errorf("lock state not cleaned up: %d devices", len(m.locks))
+	}
+}
+
+func TestDeviceLockQueueFull(t *testing.T) {
+	m := newTestLockManager(2, time.Second)
+	release, err := m.Acquire(context.Background(), testLockIP, LockExclusive)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	first := acquireAsync(t, m, context.Background(), LockShared, 1)
+	second := acquireAsync(t, m, context.Background(), LockExclusive, 2)
+
+	if _, err := m.Acquire(context.Background(), testLockIP, LockShared); !errors.Is(err, ErrDeviceBusy) {
+		t.Fatalf("Acquire on full queue: err=%v, want ErrDeviceBusy", err)
+	}
+
+	release()
+	mustGrant(t, first)()
+	mustGrant(t, second)()
+}
+
+func TestDeviceLockWaitTimeout(t *testing.T) {
+	m := newTestLockManager(4, 30*time.Millisecond)
+	release, err := m.Acquire(context.Background(), testLockIP, LockExclusive)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer release()
+
+	if _, err := m.Acquire(context.Background(), testLockIP, LockShared); !errors.Is(err, ErrLockWaitTimeout) {
+		t.Fatalf("Acquire: err=%v, want ErrLockWaitTimeout", err)
+	}
+	waitQueued(t, m, 0)
+}
+
+func TestDeviceLockWaiterRemovedMidQueue(t *testing.T) {
+	tests := []struct {
+		name        string
+		waitTimeout time.Duration
+		stagger     time.Duration // 独占请求入队后再排共享请求的间隔，使独占请求先于共享请求超时
+		cancel      bool          // 是否通过取消 ctx 移除独占请求
+		wantErr     error
+	}{
+		{"wait timeout", 100 * time.Millisecond, 60 * time.Millisecond, false, ErrLockWaitTimeout},
+		{"context canceled", time.Second, 0, true, context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestLockManager(4, tt.waitTimeout)
+			releaseShared, err := m.Acquire(context.Background(), testLockIP, LockShared)
+			if err != nil {
+				t.Fatalf("Acquire: %v", err)
+			}
+
+			// 队列: [独占, 共享, 共享]，后两个共享请求被排在前面的独占请求挡住
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			exclusive := acquireAsync(t, m, ctx, LockExclusive, 1)
+			time.Sleep(tt.stagger)
+			shared1 := acquireAsync(t, m, context.Background(), LockShared, 2)
+			shared2 := acquireAsync(t, m, context.Background(), LockShared, 3)
+			mustWait(t, shared1)
+
+			if tt.cancel {
+				cancel()
+			}
+			select {
+			case r := <-exclusive:
+				if !errors.Is(r.err, tt.wantErr) {
+					t.Fatalf("exclusive waiter: err=%v, want %v", r.err, tt.wantErr)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("exclusive waiter not removed")
+			}
+
+			// 移除独占请求后，后面的共享请求与当前持有的共享锁兼容，应立即获得
+			mustGrant(t, shared1)()
+			mustGrant(t, shared2)()
+			releaseShared()
+
+			if len(m.locks) != 0 {
+				t.Errorf("lock state not cleaned up: %d devices", len(m.locks))
+			}
+		})
+	}
+}
+
+func TestDeviceLockDisabled(t *testing.T) {
+	m := NewDeviceLockManager(config.DeviceLockConfig{Enabled: false})
+	release, err := m.Acquire(context.Background(), testLockIP, LockExclusive)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer release()
+	if _, err := m.Acquire(context.Background(), testLockIP, LockExclusive); err != nil {
+		t.Fatalf("Acquire with locking disabled: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package phone
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	icmpProtocolV4  = 1  // IANA协议号: ICMP
+	icmpProtocolV6  = 58 // IANA协议号: ICMPv6
+	icmpPayloadSize = 56 // 与ping默认一致的数据长度
+)
+
+// icmpIDCounter 区分同一进程内并发的ping，raw套接字会收到所有ICMP回包
+var icmpIDCounter atomic.Uint32
+
+// icmpConn ICMP回显套接字，优先使用raw套接字，无权限时退回非特权的datagram套接字
+type icmpConn struct {
+	conn     *icmp.PacketConn
+	target   net.IP
+	v6       bool
+	datagram bool // datagram套接字由内核改写ID，只能按序号匹配
+	id       int
+	payload  []byte
+}
+
+// nextICMPID 分配回显请求ID
+func nextICMPID() int {
+	return (os.Getpid() + int(icmpIDCounter.Add(1))) & 0xffff
+}
+
+// openICMP 创建面向目标地址的ICMP回显套接字
+func openICMP(target net.IP) (*icmpConn, error) {
+	c := &icmpConn{
+		target:  target,
+		v6:      target.To4() == nil,
+		id:      nextICMPID(),
+		payload: make([]byte, icmpPayloadSize),
+	}
+
+	rawNetwork, datagramNetwork, listenAddr := "ip4:icmp", "udp4", "0.0.0.0"
+	if c.v6 {
+		rawNetwork, datagramNetwork, listenAddr = "ip6:ipv6-icmp", "udp6", "::"
+	}
+
+	conn, err := icmp.ListenPacket(rawNetwork, listenAddr)
+	if err != nil {
+		conn, err = icmp.ListenPacket(datagramNetwork, listenAddr)
+		if err != nil {
+			return nil, fmt.Errorf("创建ICMP套接字失败: %v", err)
+		}
+		c.datagram = true
+	}
+	c.conn = conn
+	return c, nil
+}
+
+// Close 关闭套接字
+func (c *icmpConn) Close() error {
+	return c.conn.Close()
+}
+
+// destination 返回与套接字类型匹配的目标地址
+func (c *icmpConn) destination() net.Addr {
+	if c.datagram {
+		return &net.UDPAddr{IP: c.target}
+	}
+	return &net.IPAddr{IP: c.target}
+}
+
+// setTTL 设置后续发送报文的TTL（IPv6为跳数限制）
+func (c *icmpConn) setTTL(ttl int) error {
+	if c.v6 {
+		return c.conn.IPv6PacketConn().SetHopLimit(ttl)
+	}
+	return c.conn.IPv4PacketConn().SetTTL(ttl)
+}
+
+// sendEcho 发送一个回显请求
+func (c *icmpConn) sendEcho(seq int) error {
+	var msgType icmp.Type = ipv4.ICMPTypeEcho
+	if c.v6 {
+		msgType = ipv6.ICMPTypeEchoRequest
+	}
+	msg := icmp.Message{
+		Type: msgType,
+		Body: &icmp.Echo{ID: c.id, Seq: seq, Data: c.payload},
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return fmt.Errorf("构造ICMP报文失败: %v", err)
+	}
+	if _, err := c.conn.WriteTo(data, c.destination()); err != nil {
+		return fmt.Errorf("发送ICMP报文失败: %w", err)
+	}
+	return nil
+}
+
+// isUnreachableError 发送时内核已判定目标不可达（如无路由、邻居解析失败）
+func isUnreachableError(err error) bool {
+	return errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH)
+}
+
+// icmpReplyKind 与本次探测相关的ICMP报文类型
+type icmpReplyKind int
+
+const (
+	icmpEchoReply       icmpReplyKind = iota + 1 // 回显应答
+	icmpDestUnreachable                          // 目标不可达
+	icmpTimeExceeded                             // TTL超时
+)
+
+// icmpReply 收到的与本次探测相关的ICMP报文
+type icmpReply struct {
+	kind       icmpReplyKind
+	seq        int
+	code       int
+	peer       net.IP // 报文的发送方，差错报文为报告差错的路由器或主机
+	receivedAt time.Time
+}
+
+// readReply 读取下一个与本次探测相关的报文（回显应答或携带本次回显请求的差错报文），
+// 到达 deadline 时返回 nil
+func (c *icmpConn) readReply(deadline time.Time) (*icmpReply, error) {
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	protocol := icmpProtocolV4
+	if c.v6 {
+		protocol = icmpProtocolV6
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("接收ICMP报文失败: %v", err)
+		}
+		receivedAt := time.Now()
+
+		msg, err := icmp.ParseMessage(protocol, buf[:n])
+		if err != nil {
+			continue
+		}
+
+		reply := &icmpReply{code: msg.Code, peer: peerIP(peer), receivedAt: receivedAt}
+		var original []byte
+		switch body := msg.Body.(type) {
+		case *icmp.Echo:
+			if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+				continue
+			}
+			if !reply.peer.Equal(c.target) || (!c.datagram && body.ID != c.id) {
+				continue
+			}
+			reply.kind, reply.seq = icmpEchoReply, body.Seq
+			return reply, nil
+		case *icmp.DstUnreach:
+			reply.kind, original = icmpDestUnreachable, body.Data
+		case *icmp.TimeExceeded:
+			reply.kind, original = icmpTimeExceeded, body.Data
+		default:
+			continue
+		}
+
+		// 差错报文携带原始报文头，据此确认是本次探测的回显请求
+		dst, id, seq, ok := parseEmbeddedEcho(original, c.v6)
+		if !ok || !dst.Equal(c.target) || (!c.datagram && id != c.id) {
+			continue
+		}
+		reply.seq = seq
+		return reply, nil
+	}
+}
+
+// parseEmbeddedEcho 从差错报文携带的原始报文中解析回显请求的目标地址、ID和序号
+func parseEmbeddedEcho(data []byte, v6 bool) (net.IP, int, int, bool) {
+	var (
+		dst      net.IP
+		echoType byte
+	)
+	if v6 {
+		// 不处理扩展头，回显请求不会携带
+		if len(data) < ipv6.HeaderLen+8 || data[6] != icmpProtocolV6 {
+			return nil, 0, 0, false
+		}
+		dst = net.IP(data[24:40])
+		data = data[ipv6.HeaderLen:]
+		echoType = byte(ipv6.ICMPTypeEchoRequest)
+	} else {
+		if len(data) < ipv4.HeaderLen {
+			return nil, 0, 0, false
+		}
+		headerLen := int(data[0]&0x0f) * 4
+		if len(data) < headerLen+8 || data[9] != icmpProtocolV4 {
+			return nil, 0, 0, false
+		}
+		dst = net.IP(data[16:20])
+		data = data[headerLen:]
+		echoType = byte(ipv4.ICMPTypeEcho)
+	}
+	if data[0] != echoType {
+		return nil, 0, 0, false
+	}
+	return dst, int(binary.BigEndian.Uint16(data[4:6])), int(binary.BigEndian.Uint16(data[6:8])), true
+}
+
+// peerIP 返回报文发送方的IP
+func peerIP(peer net.Addr) net.IP {
+	switch addr := peer.(type) {
+	case *net.IPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package phone
+
+import (
+	"context"
+	"strings"
+)
+
+// 身份校验项
+const (
+	IdentityFieldSerialNumber = "serial_number" // ADB获取的SN码
+	IdentityFieldADBMAC       = "adb_mac"       // ADB获取的网卡MAC
+	IdentityFieldARPMAC       = "arp_mac"       // 宿主机邻居表中的MAC
+)
+
+// IdentityVerdict 身份校验结论
+type IdentityVerdict int
+
+const (
+	IdentityInconclusive IdentityVerdict = iota // 有校验项无法执行，且没有不一致项
+	IdentityMatch                               // 全部校验项一致
+	IdentityMismatch                            // 至少一项不一致
+)
+
+// IdentityCheck 单项身份校验结果
+type IdentityCheck struct {
+	Field    string
+	Expected string
+	Actual   string
+	Checked  bool   // 是否成功获取到实际值
+	Matched  bool   // Checked 为 true 时是否一致
+	Error    string // 获取实际值失败的原因
+}
+
+// IdentityResult 身份校验结果
+type IdentityResult struct {
+	Verdict          IdentityVerdict
+	Checks           []IdentityCheck
+	MismatchedFields []string
+}
+
+// VerifyIdentity 校验IP当前对应的设备是否为期望的设备
+// SN与网卡MAC通过一次ADB连接获取，另外与宿主机邻居表MAC比对；期望值为空的项不校验
+func VerifyIdentity(ctx context.Context, ipAddress, expectedSN, expectedMAC string, timeout int32) *IdentityResult {
+	expectedSN = strings.TrimSpace(expectedSN)
+	expectedMAC = strings.TrimSpace(expectedMAC)
+
+	var checks []IdentityCheck
+
+	if expectedSN != "" || expectedMAC != "" {
+		info, err := GetDeviceInfo(ctx, ipAddress, timeout)
+
+		if expectedSN != "" {
+			check := IdentityCheck{Field: IdentityFieldSerialNumber, Expected: expectedSN}
+			if err != nil {
+				check.Error = err.Error()
+			} else {
+				check.Checked = true
+				check.Actual = info.SerialNumber
+				check.Matched = info.SerialNumber == expectedSN
+			}
+			checks = append(checks, check)
+		}
+
+		if expectedMAC != "" {
+			check := IdentityCheck{Field: IdentityFieldADBMAC, Expected: expectedMAC}
+			if err != nil {
+				check.Error = err.Error()
+			} else if selected, selectErr := selectInterface(info.Interfaces, ipAddress, MACQuery{}); selectErr != nil {
+				check.Error = selectErr.Error()
+			} else {
+				// 期望MAC属于设备任一网卡即视为一致，实际值展示持有该IP的网卡
+				check.Checked = true
+				check.Actual = selected.MACAddress
+				check.Matched = !DetectMACConflict(expectedMAC, info.Interfaces)
+			}
+			checks = append(checks, check)
+		}
+	}
+
+	if expectedMAC != "" {
+		check := IdentityCheck{Field: IdentityFieldARPMAC, Expected: expectedMAC}
+		neighbor, err := LookupNeighborMAC(ctx, ipAddress, true, timeout)
+		if err != nil {
+			check.Error = err.Error()
+		} else {
+			check.Checked = true
+			check.Actual = neighbor.MACAddress
+			check.Matched = CompareMACAddress(expectedMAC, neighbor.MACAddress)
+		}
+		checks = append(checks, check)
+	}
+
+	result := &IdentityResult{Verdict: IdentityMatch, Checks: checks}
+	for _, check := range checks {
+		switch {
+		case check.Checked && !check.Matched:
+			result.Verdict = IdentityMismatch
+			result.MismatchedFields = append(result.MismatchedFields, check.Field)
+		case !check.Checked && result.Verdict == IdentityMatch:
+			result.Verdict = IdentityInconclusive
+		}
+	}
+	if len(checks) == 0 {
+		result.Verdict = IdentityInconclusive
+	}
+
+	return result
+}
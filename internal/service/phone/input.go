@@ -0,0 +1,224 @@
+package phone
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultInputTimeout  = 30  // 默认输入操作超时时间（秒）
+	defaultLongPressMs   = 800 // 默认长按时长（毫秒）
+	defaultSwipeMs       = 300 // 默认滑动时长（毫秒）
+	maxInputActions      = 200 // 单次批量输入最多动作数
+	inputFailedMarker    = "@@INPUT_FAILED "
+	adbKeyboardB64Action = "ADB_INPUT_B64"           // ADBKeyboard 接收base64文本的广播action
+	adbKeyboardPackage   = "com.android.adbkeyboard" // ADBKeyboard 包名，输入法ID形如 com.android.adbkeyboard/.AdbIME
+	broadcastDoneMarker  = "Broadcast completed"     // am broadcast 投递完成时的输出
+)
+
+var (
+	// keyCodeRe 允许的按键码: 数字或 KEYCODE_ 常量名
+	keyCodeRe = regexp.MustCompile(`^(KEYCODE_[A-Z0-9_]+|\d+)$`)
+
+	// ErrADBKeyboardInactive 需要 ADBKeyboard 输入文本，但设备当前输入法不是 ADBKeyboard
+	ErrADBKeyboardInactive = errors.New("ADBKeyboard 输入法未启用")
+)
+
+// InputActionType 输入动作类型
+type InputActionType int
+
+const (
+	InputTap       InputActionType = iota // 点击
+	InputSwipe                            // 滑动
+	InputLongPress                        // 长按
+	InputKeyEvent                         // 按键
+	InputText                             // 文本输入
+	InputWait                             // 等待
+)
+
+// InputAction 单个输入动作
+type InputAction struct {
+	Type       InputActionType
+	X, Y       int
+	EndX, EndY int    // 滑动终点
+	DurationMs int    // 滑动/长按/等待时长（毫秒）
+	KeyCode    string // 按键码，如 KEYCODE_HOME 或 3
+	LongPress  bool   // 按键是否长按
+	Text       string // 输入文本
+}
+
+// InputResult 批量输入执行结果
+type InputResult struct {
+	Completed   int // 成功执行的动作数
+	FailedIndex int // 失败动作的下标，全部成功时为 -1
+	Output      string
+}
+
+// isPlainInputText 判断文本能否直接交给 input text：仅可打印ASCII且不含 input 自身会转义的 %
+func isPlainInputText(text string) bool {
+	for _, r := range text {
+		if r < 0x20 || r > 0x7e || r == '%' {
+			return false
+		}
+	}
+	return true
+}
+
+// needsADBKeyboard 文本含非ASCII字符或 % 时 input text 无法输入，需要通过 ADBKeyboard 广播
+func needsADBKeyboard(action InputAction) bool {
+	return action.Type == InputText && action.Text != "" && !isPlainInputText(action.Text)
+}
+
+// inputActionCommand 将输入动作转换为设备shell命令
+// 含Unicode的文本通过 ADBKeyboard 的base64广播输入，要求设备已安装 ADBKeyboard 并选为当前输入法，
+// 由 SendInput 在执行前检查；广播输出中没有投递完成标记时视为失败
+func inputActionCommand(action InputAction) (string, error) {
+	switch action.Type {
+	case InputTap:
+		if action.X < 0 || action.Y < 0 {
+			return "", fmt.Errorf("坐标无效: (%d, %d)", action.X, action.Y)
+		}
+		return fmt.Sprintf("input tap %d %d", action.X, action.Y), nil
+
+	case InputSwipe, InputLongPress:
+		duration := action.DurationMs
+		endX, endY := action.EndX, action.EndY
+		if action.Type == InputLongPress {
+			// 长按即起止点相同的滑动
+			endX, endY = action.X, action.Y
+			if duration <= 0 {
+				duration = defaultLongPressMs
+			}
+		} else if duration <= 0 {
+			duration = defaultSwipeMs
+		}
+		if action.X < 0 || action.Y < 0 || endX < 0 || endY < 0 {
+			return "", fmt.Errorf("坐标无效: (%d, %d) -> (%d, %d)", action.X, action.Y, endX, endY)
+		}
+		return fmt.Sprintf("input swipe %d %d %d %d %d", action.X, action.Y, endX, endY, duration), nil
+
+	case InputKeyEvent:
+		if !keyCodeRe.MatchString(action.KeyCode) {
+			return "", fmt.Errorf("按键码无效: %s", action.KeyCode)
+		}
+		if action.LongPress {
+			return "input keyevent --longpress " + action.KeyCode, nil
+		}
+		return "input keyevent " + action.KeyCode, nil
+
+	case InputText:
+		if action.Text == "" {
+			return "", fmt.Errorf("输入文本为空")
+		}
+		if isPlainInputText(action.Text) {
+			// input text 把 %s 解释为空格，空格需替换后再整体加引号
+			return "input text " + shellQuote(strings.ReplaceAll(action.Text, " ", "%s")), nil
+		}
+		encoded := base64.StdEncoding.EncodeToString([]byte(action.Text))
+		return fmt.Sprintf("am broadcast -a %s -p %s --es msg %s | grep -q '%s'",
+			adbKeyboardB64Action, adbKeyboardPackage, encoded, broadcastDoneMarker), nil
+
+	case InputWait:
+		if action.DurationMs <= 0 {
+			return "", fmt.Errorf("等待时长无效: %d", action.DurationMs)
+		}
+		return fmt.Sprintf("sleep %d.%03d", action.DurationMs/1000, action.DurationMs%1000), nil
+
+	default:
+		return "", fmt.Errorf("不支持的输入动作: %d", action.Type)
+	}
+}
+
+// buildInputScript 将动作序列拼接为一个脚本，任一动作失败即输出失败标记并停止
+func buildInputScript(actions []InputAction) (string, error) {
+	if len(actions) == 0 {
+		return "", fmt.Errorf("输入动作为空")
+	}
+	if len(actions) > maxInputActions {
+		return "", fmt.Errorf("输入动作过多: %d, 上限 %d", len(actions), maxInputActions)
+	}
+
+	steps := make([]string, 0, len(actions))
+	for i, action := range actions {
+		command, err := inputActionCommand(action)
+		if err != nil {
+			return "", fmt.Errorf("第%d个动作无效: %v", i+1, err)
+		}
+		steps = append(steps, fmt.Sprintf("%s || { echo '%s%d'; exit 1; }", command, inputFailedMarker, i))
+	}
+	return strings.Join(steps, "\n"), nil
+}
+
+// SendInput 在一次ADB会话中按顺序执行输入动作
+func SendInput(ctx context.Context, ipAddress string, actions []InputAction, timeout int32) (*InputResult, error) {
+	script, err := buildInputScript(actions)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = defaultInputTimeout
+	}
+
+	deviceAddr := deviceAddress(ipAddress)
+	connectDevice(ctx, deviceAddr)
+
+	execCtx, execCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer execCancel()
+
+	// 没有启用 ADBKeyboard 时广播无人接收，不会报错，需在执行任何动作前检查
+	for i, action := range actions {
+		if !needsADBKeyboard(action) {
+			continue
+		}
+		if err := checkADBKeyboard(execCtx, deviceAddr); err != nil {
+			return &InputResult{FailedIndex: i}, fmt.Errorf("第%d个动作需要ADBKeyboard输入法: %w", i+1, err)
+		}
+		break
+	}
+
+	stdout, stderr, exitCode, err := runADBShell(execCtx, deviceAddr, script)
+	result := &InputResult{Completed: len(actions), FailedIndex: -1, Output: strings.TrimSpace(stdout + stderr)}
+	if err != nil {
+		return nil, fmt.Errorf("执行输入失败: %v", err)
+	}
+
+	if idx := strings.Index(stdout, inputFailedMarker); idx >= 0 {
+		field := strings.Fields(stdout[idx+len(inputFailedMarker):])
+		if len(field) > 0 {
+			if failed, convErr := strconv.Atoi(field[0]); convErr == nil {
+				result.FailedIndex = failed
+				result.Completed = failed
+			}
+		}
+		result.Output = strings.TrimSpace(strings.Replace(result.Output, inputFailedMarker+strconv.Itoa(result.FailedIndex), "", 1))
+		return result, fmt.Errorf("第%d个动作执行失败: %s", result.FailedIndex+1, result.Output)
+	}
+	if exitCode != 0 {
+		result.Completed = 0
+		return result, fmt.Errorf("输入脚本退出码非0: %d, %s", exitCode, result.Output)
+	}
+	return result, nil
+}
+
+// checkADBKeyboard 检查设备当前输入法是否为 ADBKeyboard
+func checkADBKeyboard(ctx context.Context, deviceAddr string) error {
+	stdout, stderr, exitCode, err := runADBShell(ctx, deviceAddr, "settings get secure default_input_method")
+	if err != nil {
+		return fmt.Errorf("查询当前输入法失败: %v", err)
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("查询当前输入法失败: %s", strings.TrimSpace(stderr))
+	}
+
+	current := strings.TrimSpace(stdout)
+	if !strings.HasPrefix(current, adbKeyboardPackage+"/") {
+		return fmt.Errorf("%w, 当前输入法: %s", ErrADBKeyboardInactive, current)
+	}
+	return nil
+}
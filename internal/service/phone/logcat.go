@@ -0,0 +1,238 @@
+package phone
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLogcatDumpTimeout = 30          // 非跟随模式下导出日志的默认超时时间（秒）
+	maxLogcatLineSize        = 1024 * 1024 // 单行日志最大长度
+)
+
+var (
+	// logcatLineRe -v threadtime 输出的日志行，时间为 -v epoch 的 "秒.毫秒" 或默认的 "MM-DD HH:MM:SS.mmm"
+	// 如 "1700000000.123  1234  1250 I ActivityManager: Start proc"
+	logcatLineRe = regexp.MustCompile(`^\s*(\d+\.\d+|\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d+)\s+(\d+)\s+(\d+)\s+([VDIWEFS])\s+(.*?)\s*: (.*)$`)
+	// logcatTagRe 允许作为过滤条件的tag
+	logcatTagRe = regexp.MustCompile(`^[A-Za-z0-9_.\-/$]+$`)
+
+	// logcatBuffers logcat 支持的缓冲区
+	logcatBuffers = map[string]bool{
+		"main": true, "system": true, "radio": true, "events": true,
+		"crash": true, "kernel": true, "security": true, "stats": true, "all": true, "default": true,
+	}
+)
+
+// LogcatOptions logcat 过滤选项
+type LogcatOptions struct {
+	Buffers     []string  // 缓冲区，为空使用设备默认（main/system/crash）
+	Tags        []string  // 只输出这些tag，为空不按tag过滤
+	MinPriority byte      // 最低级别 V/D/I/W/E/F，0表示不过滤
+	PackageName string    // 只输出该应用进程的日志（按启动时的PID过滤）
+	Since       time.Time // 只输出该时间之后的日志，零值表示不限制
+	Follow      bool      // 持续跟随新日志，否则导出现有日志后结束
+}
+
+// LogcatEntry 解析后的一条日志
+type LogcatEntry struct {
+	Time     time.Time // 日志时间，无法解析时为零值
+	TimeText string    // 原始时间文本
+	PID      int32
+	TID      int32
+	Level    byte // V/D/I/W/E/F
+	Tag      string
+	Message  string
+	Unparsed bool // 无法按 threadtime 格式解析，Message 为原始行
+}
+
+// buildLogcatCommand 根据过滤选项构造设备上执行的 logcat 命令
+func buildLogcatCommand(opts LogcatOptions, pid int) (string, error) {
+	args := []string{"logcat", "-v", "threadtime", "-v", "epoch"}
+	if !opts.Follow {
+		args = append(args, "-d")
+	}
+
+	for _, buffer := range opts.Buffers {
+		if !logcatBuffers[buffer] {
+			return "", fmt.Errorf("不支持的日志缓冲区: %s", buffer)
+		}
+		args = append(args, "-b", buffer)
+	}
+
+	if !opts.Since.IsZero() {
+		// -v epoch 下 -T 接受 "秒.毫秒" 格式的时间
+		ms := opts.Since.UnixMilli()
+		args = append(args, "-T", fmt.Sprintf("%d.%03d", ms/1000, ms%1000))
+	}
+
+	if pid > 0 {
+		args = append(args, "--pid="+strconv.Itoa(pid))
+	}
+
+	priority := "V"
+	if opts.MinPriority != 0 {
+		if !strings.ContainsRune("VDIWEF", rune(opts.MinPriority)) {
+			return "", fmt.Errorf("不支持的日志级别: %c", opts.MinPriority)
+		}
+		priority = string(opts.MinPriority)
+	}
+
+	// 过滤表达式: 指定tag时其余tag静默，否则对全部tag应用最低级别
+	if len(opts.Tags) > 0 {
+		for _, tag := range opts.Tags {
+			if !logcatTagRe.MatchString(tag) {
+				return "", fmt.Errorf("无效的日志tag: %s", tag)
+			}
+			args = append(args, shellQuote(tag+":"+priority))
+		}
+		args = append(args, shellQuote("*:S"))
+	} else if priority != "V" {
+		args = append(args, shellQuote("*:"+priority))
+	}
+
+	return strings.Join(args, " "), nil
+}
+
+// parseLogcatLine 解析一行 threadtime 格式日志，分隔行（"--------- beginning of main"）返回 false
+func parseLogcatLine(line string) (LogcatEntry, bool) {
+	if strings.HasPrefix(line, "--------- ") {
+		return LogcatEntry{}, false
+	}
+
+	m := logcatLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return LogcatEntry{Message: line, Unparsed: true}, true
+	}
+
+	pid, _ := strconv.ParseInt(m[2], 10, 32)
+	tid, _ := strconv.ParseInt(m[3], 10, 32)
+	entry := LogcatEntry{
+		TimeText: m[1],
+		PID:      int32(pid),
+		TID:      int32(tid),
+		Level:    m[4][0],
+		Tag:      m[5],
+		Message:  m[6],
+	}
+	if sec, frac, ok := strings.Cut(m[1], "."); ok && !strings.Contains(m[1], " ") {
+		s, _ := strconv.ParseInt(sec, 10, 64)
+		for len(frac) < 9 {
+			frac += "0"
+		}
+		ns, _ := strconv.ParseInt(frac[:9], 10, 64)
+		entry.Time = time.Unix(s, ns)
+	}
+	return entry, true
+}
+
+// resolvePackagePID 查询应用当前进程PID
+func resolvePackagePID(ctx context.Context, deviceAddr, packageName string) (int, error) {
+	if err := ValidatePackageName(packageName); err != nil {
+		return 0, err
+	}
+
+	queryCtx, queryCancel := context.WithTimeout(ctx, defaultADBTimeout*time.Second)
+	defer queryCancel()
+
+	stdout, _, _, err := runADBShell(queryCtx, deviceAddr, "pidof -s "+shellQuote(packageName))
+	if err != nil {
+		return 0, fmt.Errorf("查询应用进程失败: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(stdout))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("应用未运行: %s", packageName)
+	}
+	return pid, nil
+}
+
+// StreamLogcat 按过滤条件读取设备日志，每解析出一条即通过 onEntry 回调
+// 跟随模式下持续运行直到 ctx 取消；按应用过滤时只跟踪开始时的进程，应用重启后需重新订阅
+func StreamLogcat(ctx context.Context, ipAddress string, opts LogcatOptions, timeout int32, onEntry func(LogcatEntry) error) (int, error) {
+	deviceAddr := deviceAddress(ipAddress)
+	connectDevice(ctx, deviceAddr)
+
+	pid := 0
+	if opts.PackageName != "" {
+		var err error
+		if pid, err = resolvePackagePID(ctx, deviceAddr, opts.PackageName); err != nil {
+			return 0, err
+		}
+	}
+
+	command, err := buildLogcatCommand(opts, pid)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		execCtx    context.Context
+		execCancel context.CancelFunc
+	)
+	if !opts.Follow && timeout <= 0 {
+		timeout = defaultLogcatDumpTimeout
+	}
+	if timeout > 0 {
+		execCtx, execCancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	} else {
+		execCtx, execCancel = context.WithCancel(ctx)
+	}
+	defer execCancel()
+
+	var stderr strings.Builder
+	cmd := exec.CommandContext(execCtx, "adb", "-s", deviceAddr, "shell", command)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, fmt.Errorf("创建stdout管道失败: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("启动logcat失败: %v", err)
+	}
+
+	count := 0
+	var handlerErr error
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxLogcatLineSize)
+	for scanner.Scan() {
+		entry, ok := parseLogcatLine(strings.TrimRight(scanner.Text(), "\r"))
+		if !ok {
+			continue
+		}
+		if handlerErr = onEntry(entry); handlerErr != nil {
+			break
+		}
+		count++
+	}
+
+	// 回调失败或读取出错时终止 logcat
+	execCancel()
+	waitErr := cmd.Wait()
+
+	if handlerErr != nil {
+		return count, fmt.Errorf("转发日志失败: %v", handlerErr)
+	}
+	if ctx.Err() != nil {
+		return count, fmt.Errorf("日志读取已取消: %v", ctx.Err())
+	}
+	if execCtx.Err() == context.DeadlineExceeded {
+		// 跟随模式下到达超时是正常结束
+		if opts.Follow {
+			return count, nil
+		}
+		return count, fmt.Errorf("导出日志超时")
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("读取日志失败: %v", err)
+	}
+	if waitErr != nil {
+		return count, fmt.Errorf("执行logcat失败: %v, %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return count, nil
+}
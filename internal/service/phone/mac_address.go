@@ -0,0 +1,129 @@
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// listInterfacesScript 枚举 /sys/class/net 下全部网卡及其IPv4地址
+// 输出行格式: "iface 名称 类型 MAC" 与 "inet4 <ip -o -4 addr 输出>"
+const listInterfacesScript = `for i in /sys/class/net/*; do echo "iface ${i##*/} $(cat $i/type 2>/dev/null) $(cat $i/address 2>/dev/null)"; done; ip -o -4 addr show 2>/dev/null | sed 's/^/inet4 /'`
+
+// skippedInterfaceTypes 不参与MAC查询的网卡类型（include/uapi/linux/if_arp.h）
+var skippedInterfaceTypes = map[string]bool{
+	"768":   true, // ARPHRD_TUNNEL
+	"769":   true, // ARPHRD_TUNNEL6
+	"772":   true, // ARPHRD_LOOPBACK
+	"776":   true, // ARPHRD_SIT
+	"778":   true, // ARPHRD_IPGRE
+	"823":   true, // ARPHRD_IP6GRE
+	"65534": true, // ARPHRD_NONE（tun等）
+}
+
+// skippedInterfacePrefixes 不参与MAC查询的虚拟网卡名前缀
+var skippedInterfacePrefixes = []string{"lo", "dummy", "ifb", "sit", "tunl", "ip6tnl", "ip_vti", "ip6_vti", "gre", "erspan"}
+
+// preferredInterfaces 未指定网卡且找不到持有设备IP的网卡时的优先顺序
+var preferredInterfaces = []string{"eth0", "wlan0"}
+
+// InterfaceMAC 网卡及其MAC地址
+type InterfaceMAC struct {
+	Name          string
+	MACAddress    string
+	IPv4Addresses []string
+}
+
+// MACQuery MAC地址查询条件
+type MACQuery struct {
+	Interface string // 指定网卡名，优先级最高
+	MatchIP   bool   // 只接受持有设备IP的网卡
+}
+
+// parseInterfaceMACs 解析 listInterfacesScript 输出，跳过回环、隧道等虚拟网卡和无MAC的网卡
+func parseInterfaceMACs(output string) []InterfaceMAC {
+	var (
+		interfaces []InterfaceMAC
+		addrs      = make(map[string][]string)
+	)
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "iface":
+			// iface 名称 类型 MAC，无MAC的网卡（如rmnet）只有3列
+			if len(fields) != 4 || skippedInterfaceTypes[fields[2]] || hasSkippedPrefix(fields[1]) {
+				continue
+			}
+			mac := strings.ToLower(fields[3])
+			if _, err := net.ParseMAC(mac); err != nil || mac == "00:00:00:00:00:00" {
+				continue
+			}
+			interfaces = append(interfaces, InterfaceMAC{Name: fields[1], MACAddress: mac})
+		case "inet4":
+			// inet4 2: eth0    inet 10.0.0.5/24 brd 10.0.0.255 scope global eth0
+			if len(fields) < 5 || fields[3] != "inet" {
+				continue
+			}
+			name := strings.SplitN(fields[2], "@", 2)[0]
+			ip := strings.SplitN(fields[4], "/", 2)[0]
+			addrs[name] = append(addrs[name], ip)
+		}
+	}
+
+	for i := range interfaces {
+		interfaces[i].IPv4Addresses = addrs[interfaces[i].Name]
+	}
+	return interfaces
+}
+
+// hasSkippedPrefix 是否为需跳过的虚拟网卡名
+func hasSkippedPrefix(name string) bool {
+	for _, prefix := range skippedInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectInterface 按查询条件选出目标网卡
+// 优先级：指定网卡名 > 持有设备IP的网卡 > eth0/wlan0 > 第一个网卡（MatchIP时不回退）
+func selectInterface(interfaces []InterfaceMAC, ipAddress string, query MACQuery) (*InterfaceMAC, error) {
+	if len(interfaces) == 0 {
+		return nil, errors.New("未找到带MAC的网卡")
+	}
+	if query.Interface != "" {
+		for i := range interfaces {
+			if interfaces[i].Name == query.Interface {
+				return &interfaces[i], nil
+			}
+		}
+		return nil, fmt.Errorf("未找到网卡 %s 或该网卡无MAC地址", query.Interface)
+	}
+
+	for i := range interfaces {
+		for _, addr := range interfaces[i].IPv4Addresses {
+			if addr == ipAddress {
+				return &interfaces[i], nil
+			}
+		}
+	}
+	if query.MatchIP {
+		return nil, fmt.Errorf("未找到持有IP %s 的网卡", ipAddress)
+	}
+
+	for _, name := range preferredInterfaces {
+		for i := range interfaces {
+			if interfaces[i].Name == name {
+				return &interfaces[i], nil
+			}
+		}
+	}
+	return &interfaces[0], nil
+}
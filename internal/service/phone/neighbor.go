@@ -0,0 +1,112 @@
+package phone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/wumitech-com/mdcp_server_operator/internal/hostns"
+)
+
+// NeighborEntry 宿主机邻居表（ARP）条目
+type NeighborEntry struct {
+	IPAddress  string
+	MACAddress string
+	Device     string // 宿主机上的出口网卡
+	State      string // REACHABLE / STALE / DELAY / PROBE / PERMANENT 等
+}
+
+// ErrInvalidNeighborIP 邻居表查询的IP不是有效的IPv4地址
+var ErrInvalidNeighborIP = errors.New("不是有效的IPv4地址")
+
+// LookupNeighborMAC 从宿主机网络命名空间的邻居表中查询设备MAC，不依赖adbd
+// probe 为 true 且邻居表中没有有效条目时，先在宿主机上ping一次以触发ARP解析
+func LookupNeighborMAC(ctx context.Context, ipAddress string, probe bool, timeout int32) (*NeighborEntry, error) {
+	// IP作为参数传给宿主机上的 ping / ip 命令，必须先校验，避免 "-f" 等值被当作选项
+	ip := net.ParseIP(ipAddress).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidNeighborIP, ipAddress)
+	}
+	ipAddress = ip.String()
+
+	if timeout <= 0 {
+		timeout = defaultADBTimeout
+	}
+
+	lookupCtx, lookupCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer lookupCancel()
+
+	entry, err := queryNeighbor(lookupCtx, ipAddress)
+	if err == nil || !probe {
+		return entry, err
+	}
+
+	// 邻居表中没有可用条目，ping一次促使内核发起ARP解析（ping本身失败不影响结果）
+	_, _ = hostns.Run(lookupCtx, "ping", "-c", "1", "-W", "1", ipAddress)
+
+	return queryNeighbor(lookupCtx, ipAddress)
+}
+
+// queryNeighbor 查询宿主机邻居表中的单个IP
+func queryNeighbor(ctx context.Context, ipAddress string) (*NeighborEntry, error) {
+	output, err := hostns.Run(ctx, "ip", "-4", "neigh", "show", "to", ipAddress)
+	if err != nil {
+		return nil, fmt.Errorf("查询邻居表失败: %v", err)
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if entry := parseNeighborLine(line); entry != nil && entry.IPAddress == ipAddress {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("邻居表中没有 %s 的有效条目", ipAddress)
+}
+
+// parseNeighborLine 解析 ip neigh 输出行，如
+// "192.168.1.20 dev br0 lladdr 02:11:22:33:44:55 REACHABLE"，无MAC（INCOMPLETE/FAILED）时返回 nil
+func parseNeighborLine(line string) *NeighborEntry {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil
+	}
+
+	entry := &NeighborEntry{IPAddress: fields[0]}
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "dev":
+			if i+1 < len(fields) {
+				entry.Device = fields[i+1]
+				i++
+			}
+		case "lladdr":
+			if i+1 < len(fields) {
+				entry.MACAddress = strings.ToLower(fields[i+1])
+				i++
+			}
+		default:
+			// 状态为全大写单词，其余如 router/proto 等标记忽略
+			if strings.ToUpper(fields[i]) == fields[i] {
+				entry.State = fields[i]
+			}
+		}
+	}
+
+	if entry.MACAddress == "" || entry.State == "FAILED" || entry.State == "INCOMPLETE" {
+		return nil
+	}
+	return entry
+}
+
+// DetectMACConflict 判断邻居表中的MAC是否不属于设备上报的任何网卡
+// 不一致说明该IP当前由另一台设备应答，可能存在IP冲突或IP被重新分配
+func DetectMACConflict(neighborMAC string, interfaces []InterfaceMAC) bool {
+	for _, iface := range interfaces {
+		if CompareMACAddress(neighborMAC, iface.MACAddress) {
+			return false
+		}
+	}
+	return true
+}
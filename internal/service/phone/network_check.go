@@ -2,72 +2,310 @@ package phone
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
-	"os/exec"
-	"regexp"
-	"strconv"
+	"math"
+	"net"
 	"strings"
+	"syscall"
 	"time"
+
+	"github.com/wumitech-com/mdcp_server_operator/internal/hostns"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
 )
 
 const (
-	defaultPingTimeout = 3 // 默认Ping超时时间（秒）
-	defaultPingCount   = 3 // 默认Ping次数
+	defaultPingTimeout  = 3                      // 默认单次探测超时时间（秒）
+	defaultPingCount    = 3                      // 默认Ping次数
+	defaultPingInterval = 200 * time.Millisecond // 相邻两次探测的发送间隔
+	pingDeadlineSlack   = time.Second            // 未指定整体超时时，在理论耗时之外预留的余量
+
+	MaxPingCount        = 1000             // 单次Ping探测次数上限，远小于ICMP序号范围(65535)，序号不会回绕
+	MaxPingProbeTimeout = 30 * time.Second // 单次探测超时上限
+	MaxPingInterval     = 10 * time.Second // 发送间隔上限
+	MaxPingTimeout      = 5 * time.Minute  // 整体超时上限，超过时截断
 )
 
-// ExecutePing 执行Ping命令检测网络连通性
-func ExecutePing(ipAddress string, timeout, count int32) (bool, float64, error) {
-	if timeout <= 0 {
-		timeout = defaultPingTimeout
-	}
-	if count <= 0 {
-		count = defaultPingCount
+var (
+	// ErrPingDNSFailure 目标地址解析失败
+	ErrPingDNSFailure = errors.New("解析地址失败")
+	// ErrPingHostUnreachable 全部探测均未收到应答，且收到了目标不可达
+	ErrPingHostUnreachable = errors.New("目标主机不可达")
+	// ErrPingTotalLoss 全部探测均未收到应答
+	ErrPingTotalLoss = errors.New("全部丢包")
+	// ErrPingDeadlineExceeded 整体超时，探测未全部完成
+	ErrPingDeadlineExceeded = errors.New("ping整体超时")
+	// ErrPingInvalidOptions 探测次数、超时或间隔超出上限
+	ErrPingInvalidOptions = errors.New("ping参数超出上限")
+)
+
+// PingOutcome Ping结果分类
+type PingOutcome int
+
+const (
+	PingOutcomeOK               PingOutcome = iota // 全部收到应答
+	PingOutcomePartialLoss                         // 部分丢包
+	PingOutcomeTotalLoss                           // 全部丢包
+	PingOutcomeHostUnreachable                     // 目标不可达
+	PingOutcomeDNSFailure                          // 地址解析失败
+	PingOutcomeDeadlineExceeded                    // 整体超时
+	PingOutcomeError                               // 其他错误，如无法创建套接字或已取消
+)
+
+// PingOptions Ping参数
+type PingOptions struct {
+	Count        int32
+	ProbeTimeout time.Duration // 单次探测等待应答的时间
+	Timeout      time.Duration // 整体超时，<=0 时按 (Count-1)*Interval+ProbeTimeout 加余量计算，不超过 MaxPingTimeout
+	Interval     time.Duration // 发送间隔，不等待上一次应答
+}
+
+// Validate 检查探测次数、单次超时和发送间隔是否超出上限
+func (o PingOptions) Validate() error {
+	switch {
+	case o.Count > MaxPingCount:
+		return fmt.Errorf("%w: 探测次数%d超过%d", ErrPingInvalidOptions, o.Count, MaxPingCount)
+	case o.ProbeTimeout > MaxPingProbeTimeout:
+		return fmt.Errorf("%w: 单次超时%v超过%v", ErrPingInvalidOptions, o.ProbeTimeout, MaxPingProbeTimeout)
+	case o.Interval > MaxPingInterval:
+		return fmt.Errorf("%w: 发送间隔%v超过%v", ErrPingInvalidOptions, o.Interval, MaxPingInterval)
+	case o.Timeout > MaxPingTimeout:
+		return fmt.Errorf("%w: 整体超时%v超过%v", ErrPingInvalidOptions, o.Timeout, MaxPingTimeout)
 	}
+	return nil
+}
+
+// PingProbe 单次探测结果
+type PingProbe struct {
+	Seq         int
+	Received    bool
+	Unreachable bool // 收到目标不可达
+	RTT         time.Duration
+}
+
+// PingStats Ping统计结果，只统计已得出结果的探测（整体超时时仍在等待的探测不计入）
+type PingStats struct {
+	Address         string
+	Outcome         PingOutcome
+	Probes          []PingProbe
+	Sent            int
+	Received        int
+	Unreachable     int    // 收到目标不可达的探测数
+	UnreachableFrom string // 最近一次报告目标不可达的地址
+	Min             time.Duration
+	Avg             time.Duration
+	Max             time.Duration
+	StdDev          time.Duration
+	Jitter          time.Duration // 相邻两次应答RTT之差的平均值
+	LossPercent     float64
+}
 
-	// 使用ping命令检测网络
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout+1)*time.Second)
-	defer cancel()
+// Success 至少收到一次应答即视为连通
+func (s *PingStats) Success() bool {
+	return s.Received > 0
+}
 
-	// 直接使用ping命令（与mdcp_core_executor保持一致）
-	// 容器使用Docker网络（online-hk_mdcp-network），可以直接访问宿主机网络中的IP
-	pingArgs := []string{
-		"-c", strconv.Itoa(int(count)),
-		"-W", strconv.Itoa(int(timeout)),
-		ipAddress,
+// ExecutePing 发送ICMP回显请求检测网络连通性
+// 按固定间隔发送、不等待上一次应答，每次探测在 ProbeTimeout 内未收到应答记为丢包；
+// 部分丢包不返回错误，全部丢包、目标不可达、解析失败和整体超时分别返回对应的 ErrPing* 错误，
+// 除解析失败外同时返回已完成探测的统计；参数超出上限时返回 ErrPingInvalidOptions
+func ExecutePing(ctx context.Context, ipAddress string, opts PingOptions) (*PingStats, error) {
+	if err := opts.Validate(); err != nil {
+		return &PingStats{Address: ipAddress, Outcome: PingOutcomeError}, err
+	}
+	if opts.Count <= 0 {
+		opts.Count = defaultPingCount
+	}
+	if opts.ProbeTimeout <= 0 {
+		opts.ProbeTimeout = defaultPingTimeout * time.Second
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultPingInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = min(time.Duration(opts.Count-1)*opts.Interval+opts.ProbeTimeout+pingDeadlineSlack, MaxPingTimeout)
 	}
-	cmd := exec.CommandContext(ctx, "ping", pingArgs...)
-	output, err := cmd.CombinedOutput()
+	count := int(opts.Count)
 
+	stats := &PingStats{Address: ipAddress}
+	target := net.ParseIP(ipAddress)
+	if target == nil {
+		addr, err := net.ResolveIPAddr("ip", ipAddress)
+		if err != nil {
+			stats.Outcome = PingOutcomeDNSFailure
+			return stats, fmt.Errorf("%w: %v", ErrPingDNSFailure, err)
+		}
+		target = addr.IP
+	}
+	stats.Address = target.String()
+
+	conn, err := openICMP(target)
 	if err != nil {
-		// 如果返回非0退出码，说明ping失败
-		if ctx.Err() == context.DeadlineExceeded {
-			return false, 0, fmt.Errorf("ping超时")
+		stats.Outcome = PingOutcomeError
+		return stats, err
+	}
+	defer conn.Close()
+
+	pingCtx, pingCancel := context.WithTimeout(ctx, opts.Timeout)
+	defer pingCancel()
+	// 取消时立即打断阻塞中的读取
+	stop := context.AfterFunc(pingCtx, func() { _ = conn.conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	probes := make([]PingProbe, count)
+	sentAt := make([]time.Time, count)
+	done := make([]bool, count)
+	sent, completed, oldest := 0, 0, 0
+	start := time.Now()
+
+	finish := func(seq int) {
+		done[seq] = true
+		completed++
+	}
+
+	for completed < count && pingCtx.Err() == nil {
+		now := time.Now()
+
+		// 发送到期的探测
+		if sent < count && !now.Before(start.Add(time.Duration(sent)*opts.Interval)) {
+			seq := sent
+			probes[seq].Seq = seq
+			sentAt[seq] = now
+			sent++
+			if err := conn.sendEcho(seq); err != nil {
+				if !isUnreachableError(err) {
+					stats.Outcome = PingOutcomeError
+					return stats, err
+				}
+				// 内核已判定不可达（如邻居解析失败），本次探测直接记为不可达
+				probes[seq].Unreachable = true
+				finish(seq)
+			}
+			continue
+		}
+
+		// 超过单次超时仍未应答的探测记为丢包。探测按序号顺序发送且超时相同，
+		// 到期顺序即发送顺序，只需从最早未完成的探测向后推进
+		for oldest < sent && (done[oldest] || !now.Before(sentAt[oldest].Add(opts.ProbeTimeout))) {
+			if !done[oldest] {
+				finish(oldest)
+			}
+			oldest++
+		}
+		var wake time.Time
+		if sent < count {
+			wake = start.Add(time.Duration(sent) * opts.Interval)
+		}
+		if oldest < sent {
+			if expiry := sentAt[oldest].Add(opts.ProbeTimeout); wake.IsZero() || expiry.Before(wake) {
+				wake = expiry
+			}
+		}
+		if completed == count {
+			break
+		}
+
+		reply, err := conn.readReply(wake)
+		if err != nil {
+			if pingCtx.Err() != nil {
+				break
+			}
+			stats.Outcome = PingOutcomeError
+			return stats, err
+		}
+		if reply == nil || reply.seq >= sent || done[reply.seq] || reply.receivedAt.After(sentAt[reply.seq].Add(opts.ProbeTimeout)) {
+			continue
+		}
+
+		switch reply.kind {
+		case icmpEchoReply:
+			probes[reply.seq].Received = true
+			probes[reply.seq].RTT = reply.receivedAt.Sub(sentAt[reply.seq])
+			finish(reply.seq)
+		case icmpDestUnreachable:
+			probes[reply.seq].Unreachable = true
+			stats.UnreachableFrom = reply.peer.String()
+			finish(reply.seq)
 		}
-		return false, 0, nil
 	}
 
-	// 解析ping输出，提取平均延迟
-	// 输出格式示例: "round-trip min/avg/max/stddev = 1.234/2.345/3.456/0.123 ms"
-	outputStr := string(output)
-	avgLatency := parsePingLatency(outputStr)
+	for seq := 0; seq < count; seq++ {
+		if done[seq] {
+			stats.Probes = append(stats.Probes, probes[seq])
+		}
+	}
+	stats.summarize()
 
-	return true, avgLatency, nil
+	switch {
+	case completed < count && ctx.Err() == context.Canceled:
+		stats.Outcome = PingOutcomeError
+		return stats, fmt.Errorf("ping已取消: %v", ctx.Err())
+	case completed < count:
+		stats.Outcome = PingOutcomeDeadlineExceeded
+		return stats, fmt.Errorf("%w(%v): 已完成%d/%d次探测", ErrPingDeadlineExceeded, opts.Timeout, completed, count)
+	case stats.Received == 0 && stats.Unreachable > 0:
+		stats.Outcome = PingOutcomeHostUnreachable
+		if stats.UnreachableFrom != "" {
+			return stats, fmt.Errorf("%w: 由%s报告", ErrPingHostUnreachable, stats.UnreachableFrom)
+		}
+		return stats, ErrPingHostUnreachable
+	case stats.Received == 0:
+		stats.Outcome = PingOutcomeTotalLoss
+		return stats, fmt.Errorf("%w: %d次探测均未在%v内收到应答", ErrPingTotalLoss, stats.Sent, opts.ProbeTimeout)
+	case stats.Received < stats.Sent:
+		stats.Outcome = PingOutcomePartialLoss
+	default:
+		stats.Outcome = PingOutcomeOK
+	}
+	return stats, nil
 }
 
-// parsePingLatency 解析ping输出中的平均延迟
-func parsePingLatency(output string) float64 {
-	// 匹配 "min/avg/max/stddev = xx.xx/yy.yy/zz.zz/ww.ww ms" 或类似格式
-	re := regexp.MustCompile(`min/avg/max/(?:stddev|mdev)\s*=\s*[\d.]+/([\d.]+)/[\d.]+/[\d.]+\s*ms`)
-	matches := re.FindStringSubmatch(output)
-
-	if len(matches) >= 2 {
-		latency, err := strconv.ParseFloat(matches[1], 64)
-		if err == nil {
-			return latency
+// summarize 根据探测结果计算丢包率、RTT统计和抖动
+func (s *PingStats) summarize() {
+	var (
+		sum, sumSquares float64
+		jitterSum       float64
+		previous        time.Duration
+	)
+	for _, probe := range s.Probes {
+		if probe.Unreachable {
+			s.Unreachable++
 		}
+		if !probe.Received {
+			continue
+		}
+		if s.Received == 0 || probe.RTT < s.Min {
+			s.Min = probe.RTT
+		}
+		if probe.RTT > s.Max {
+			s.Max = probe.RTT
+		}
+		if s.Received > 0 {
+			jitterSum += math.Abs(float64(probe.RTT - previous))
+		}
+		previous = probe.RTT
+		sum += float64(probe.RTT)
+		sumSquares += float64(probe.RTT) * float64(probe.RTT)
+		s.Received++
 	}
 
-	return 0
+	s.Sent = len(s.Probes)
+	if s.Sent > 0 {
+		s.LossPercent = float64(s.Sent-s.Received) * 100 / float64(s.Sent)
+	}
+	if s.Received == 0 {
+		return
+	}
+
+	mean := sum / float64(s.Received)
+	s.Avg = time.Duration(mean)
+	// 与ping的mdev一致，使用总体标准差
+	s.StdDev = time.Duration(math.Sqrt(math.Max(sumSquares/float64(s.Received)-mean*mean, 0)))
+	if s.Received > 1 {
+		s.Jitter = time.Duration(jitterSum / float64(s.Received-1))
+	}
 }
 
 // CompareMACAddress 比较两个MAC地址是否相同（忽略大小写和分隔符差异）
@@ -82,3 +320,436 @@ func CompareMACAddress(mac1, mac2 string) bool {
 
 	return normalize(mac1) == normalize(mac2)
 }
+
+const (
+	defaultTraceMaxHops      = 30
+	maxTraceMaxHops          = 64
+	defaultTraceProbesPerHop = 3
+	maxTraceProbesPerHop     = 10
+	defaultTraceProbeTimeout = time.Second
+	defaultDiagnoseTimeout   = 60 * time.Second
+	traceSendInterval        = 10 * time.Millisecond // 路由跟踪相邻探测的发送间隔，避免触发路由器ICMP限速
+
+	minPathMTU       = 68 // IPv4最小MTU
+	defaultMaxMTU    = 1500
+	maxPathMTU       = 9000
+	ipv4HeaderLen    = 20
+	icmpEchoHeadLen  = 8
+	mtuProbeAttempts = 2 // 每个尺寸无应答时的尝试次数，区分丢包和超过路径MTU
+)
+
+// 探测所在的网络命名空间
+const (
+	NetnsHost      = "host"      // 宿主机网络命名空间
+	NetnsContainer = "container" // 容器自身网络命名空间（无法进入宿主机命名空间时）
+)
+
+// DiagnoseOptions 网络路径诊断参数
+type DiagnoseOptions struct {
+	MaxHops      int           // 路由跟踪最大跳数
+	ProbesPerHop int           // 每跳探测次数
+	ProbeTimeout time.Duration // 单次探测等待应答的时间
+	MaxMTU       int           // 路径MTU探测上限
+	Timeout      time.Duration // 整体超时
+	SkipTrace    bool
+	SkipMTU      bool
+}
+
+// TraceHop 路由跟踪中的一跳，Stats 的 Probes 按轮次记录每次探测
+type TraceHop struct {
+	TTL         int
+	Addresses   []string // 应答地址，多路径时可能有多个
+	Reached     bool     // 目标本身应答
+	Unreachable bool     // 该跳报告目标不可达
+	Stats       PingStats
+}
+
+// NetworkDiagnosis 网络路径诊断结果，路由跟踪和MTU探测各自失败时记录错误，不影响另一项
+type NetworkDiagnosis struct {
+	Address    string
+	Namespace  string
+	Hops       []TraceHop
+	Reached    bool
+	TraceError string
+	PathMTU    int // 0表示未探测或探测失败
+	MTUError   string
+}
+
+// DiagnosePhoneNetwork 从宿主机网络命名空间诊断到云手机的网络路径：
+// 逐TTL发送ICMP回显进行路由跟踪并统计每跳RTT，再以禁止分片的回显请求二分探测路径MTU
+func DiagnosePhoneNetwork(ctx context.Context, ipAddress string, opts DiagnoseOptions) (*NetworkDiagnosis, error) {
+	if opts.MaxHops <= 0 {
+		opts.MaxHops = defaultTraceMaxHops
+	}
+	if opts.MaxHops > maxTraceMaxHops {
+		opts.MaxHops = maxTraceMaxHops
+	}
+	if opts.ProbesPerHop <= 0 {
+		opts.ProbesPerHop = defaultTraceProbesPerHop
+	}
+	if opts.ProbesPerHop > maxTraceProbesPerHop {
+		opts.ProbesPerHop = maxTraceProbesPerHop
+	}
+	if opts.ProbeTimeout <= 0 {
+		opts.ProbeTimeout = defaultTraceProbeTimeout
+	}
+	if opts.MaxMTU <= 0 {
+		opts.MaxMTU = defaultMaxMTU
+	}
+	if opts.MaxMTU > maxPathMTU {
+		opts.MaxMTU = maxPathMTU
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultDiagnoseTimeout
+	}
+
+	target := net.ParseIP(ipAddress)
+	if target == nil {
+		addr, err := net.ResolveIPAddr("ip", ipAddress)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPingDNSFailure, err)
+		}
+		target = addr.IP
+	}
+
+	diagCtx, diagCancel := context.WithTimeout(ctx, opts.Timeout)
+	defer diagCancel()
+
+	result := &NetworkDiagnosis{Address: target.String(), Namespace: NetnsHost}
+
+	// 套接字在宿主机命名空间中创建，之后的收发都走宿主机的路由
+	var (
+		traceConn *icmpConn
+		mtuConn   net.PacketConn
+		traceErr  error
+		mtuErr    error
+	)
+	open := func() error {
+		if !opts.SkipTrace {
+			traceConn, traceErr = openICMP(target)
+		}
+		if !opts.SkipMTU {
+			mtuConn, mtuErr = openMTUConn(target)
+		}
+		return nil
+	}
+	if err := hostns.Do(open); err != nil {
+		if !errors.Is(err, hostns.ErrUnavailable) {
+			return nil, err
+		}
+		result.Namespace = NetnsContainer
+		_ = open()
+	}
+
+	if !opts.SkipTrace {
+		if traceErr == nil {
+			traceErr = traceRoute(diagCtx, traceConn, opts, result)
+			traceConn.Close()
+		}
+		if traceErr != nil {
+			result.TraceError = traceErr.Error()
+		}
+	}
+	if !opts.SkipMTU {
+		if mtuErr == nil {
+			result.PathMTU, mtuErr = discoverPathMTU(diagCtx, mtuConn, target, opts)
+			mtuConn.Close()
+		}
+		if mtuErr != nil {
+			result.MTUError = mtuErr.Error()
+		}
+	}
+	return result, nil
+}
+
+// traceProbe 路由跟踪中的一次探测
+type traceProbe struct {
+	ttl    int
+	round  int
+	sentAt time.Time
+	sent   bool
+	done   bool
+	reply  *icmpReply
+}
+
+// traceRoute 逐TTL发送回显请求，每轮对全部TTL各发一次，收到目标应答或不可达后不再发送更大的TTL
+func traceRoute(ctx context.Context, conn *icmpConn, opts DiagnoseOptions, result *NetworkDiagnosis) error {
+	if conn.datagram {
+		return fmt.Errorf("路由跟踪需要raw套接字权限")
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = conn.conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	// 序号 = 轮次*最大跳数 + (TTL-1)
+	probes := make([]traceProbe, opts.MaxHops*opts.ProbesPerHop)
+	for i := range probes {
+		probes[i].round = i / opts.MaxHops
+		probes[i].ttl = i%opts.MaxHops + 1
+	}
+	lastTTL := opts.MaxHops // 目标或不可达报告所在的最小TTL
+	next := 0               // 下一个待发送的探测
+	nextSendAt := time.Now()
+
+	for ctx.Err() == nil {
+		now := time.Now()
+
+		for next < len(probes) && probes[next].ttl > lastTTL {
+			next++
+		}
+		if next < len(probes) && !now.Before(nextSendAt) {
+			probe := &probes[next]
+			if err := conn.setTTL(probe.ttl); err != nil {
+				return fmt.Errorf("设置TTL失败: %v", err)
+			}
+			probe.sentAt, probe.sent = now, true
+			if err := conn.sendEcho(next); err != nil {
+				if !isUnreachableError(err) {
+					return err
+				}
+				probe.done = true
+			}
+			next++
+			nextSendAt = now.Add(traceSendInterval)
+			continue
+		}
+
+		var wake time.Time
+		if next < len(probes) {
+			wake = nextSendAt
+		}
+		pending := false
+		for i := range probes {
+			probe := &probes[i]
+			if !probe.sent || probe.done {
+				continue
+			}
+			expiry := probe.sentAt.Add(opts.ProbeTimeout)
+			if !now.Before(expiry) {
+				probe.done = true
+				continue
+			}
+			pending = true
+			if wake.IsZero() || expiry.Before(wake) {
+				wake = expiry
+			}
+		}
+		if !pending && next >= len(probes) {
+			break
+		}
+
+		reply, err := conn.readReply(wake)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+		if reply == nil || reply.seq >= len(probes) {
+			continue
+		}
+		probe := &probes[reply.seq]
+		if !probe.sent || probe.done {
+			continue
+		}
+		probe.done, probe.reply = true, reply
+		if reply.kind != icmpTimeExceeded && probe.ttl < lastTTL {
+			lastTTL = probe.ttl
+		}
+	}
+
+	// 按TTL汇总，去掉末尾全部无应答的跳
+	hops := make([]TraceHop, lastTTL)
+	lastResponsive := 0
+	for i := range hops {
+		hops[i].TTL = i + 1
+	}
+	for i := range probes {
+		probe := &probes[i]
+		if !probe.sent || probe.ttl > lastTTL {
+			continue
+		}
+		hop := &hops[probe.ttl-1]
+		pingProbe := PingProbe{Seq: probe.round}
+		if reply := probe.reply; reply != nil {
+			address := reply.peer.String()
+			if !containsString(hop.Addresses, address) {
+				hop.Addresses = append(hop.Addresses, address)
+			}
+			switch reply.kind {
+			case icmpEchoReply:
+				hop.Reached = true
+			case icmpDestUnreachable:
+				hop.Unreachable = true
+				pingProbe.Unreachable = true
+			}
+			pingProbe.Received = reply.kind != icmpDestUnreachable
+			pingProbe.RTT = reply.receivedAt.Sub(probe.sentAt)
+			if probe.ttl > lastResponsive {
+				lastResponsive = probe.ttl
+			}
+		}
+		hop.Stats.Probes = append(hop.Stats.Probes, pingProbe)
+	}
+	hops = hops[:lastResponsive]
+	for i := range hops {
+		hops[i].Stats.summarize()
+		if len(hops[i].Addresses) > 0 {
+			hops[i].Stats.Address = hops[i].Addresses[0]
+		}
+		if hops[i].Reached {
+			result.Reached = true
+		}
+	}
+	result.Hops = hops
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("路由跟踪未完成: %v", ctx.Err())
+	}
+	return nil
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// openMTUConn 创建路径MTU探测使用的raw套接字，报文头由调用方构造以设置禁止分片
+func openMTUConn(target net.IP) (net.PacketConn, error) {
+	if target.To4() == nil {
+		return nil, fmt.Errorf("暂不支持IPv6路径MTU探测")
+	}
+	conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, fmt.Errorf("路径MTU探测需要raw套接字权限: %v", err)
+	}
+	return conn, nil
+}
+
+// discoverPathMTU 发送禁止分片的回显请求二分查找路径MTU
+// 收到“需要分片”时按其携带的下一跳MTU收窄上限；超过本机出口MTU时发送直接失败
+func discoverPathMTU(ctx context.Context, conn net.PacketConn, target net.IP, opts DiagnoseOptions) (int, error) {
+	rawConn, err := ipv4.NewRawConn(conn)
+	if err != nil {
+		return 0, fmt.Errorf("创建raw连接失败: %v", err)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = rawConn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	id := nextICMPID()
+	seq := 0
+	buf := make([]byte, maxPathMTU+ipv4HeaderLen)
+
+	// probe 发送一个总长度为 size 的报文，返回是否收到应答以及“需要分片”携带的MTU
+	probe := func(size int) (bool, int, error) {
+		seq = (seq + 1) & 0xffff
+		msg := icmp.Message{
+			Type: ipv4.ICMPTypeEcho,
+			Body: &icmp.Echo{ID: id, Seq: seq, Data: make([]byte, size-ipv4HeaderLen-icmpEchoHeadLen)},
+		}
+		payload, err := msg.Marshal(nil)
+		if err != nil {
+			return false, 0, fmt.Errorf("构造ICMP报文失败: %v", err)
+		}
+		header := &ipv4.Header{
+			Version:  ipv4.Version,
+			Len:      ipv4HeaderLen,
+			TotalLen: ipv4HeaderLen + len(payload),
+			Flags:    ipv4.DontFragment,
+			TTL:      64,
+			Protocol: icmpProtocolV4,
+			Dst:      target,
+		}
+		if err := rawConn.WriteTo(header, payload, nil); err != nil {
+			if errors.Is(err, syscall.EMSGSIZE) {
+				return false, 0, nil
+			}
+			if isUnreachableError(err) {
+				return false, 0, ErrPingHostUnreachable
+			}
+			return false, 0, fmt.Errorf("发送ICMP报文失败: %v", err)
+		}
+
+		if err := rawConn.SetReadDeadline(time.Now().Add(opts.ProbeTimeout)); err != nil {
+			return false, 0, err
+		}
+		for {
+			replyHeader, p, _, err := rawConn.ReadFrom(buf)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					if ctx.Err() != nil {
+						return false, 0, ctx.Err()
+					}
+					return false, 0, nil
+				}
+				return false, 0, fmt.Errorf("接收ICMP报文失败: %v", err)
+			}
+			if len(p) < icmpEchoHeadLen {
+				continue
+			}
+			switch {
+			case p[0] == byte(ipv4.ICMPTypeEchoReply) && replyHeader.Src.Equal(target) &&
+				int(binary.BigEndian.Uint16(p[4:6])) == id && int(binary.BigEndian.Uint16(p[6:8])) == seq:
+				return true, 0, nil
+			case p[0] == byte(ipv4.ICMPTypeDestinationUnreachable):
+				dst, embeddedID, embeddedSeq, ok := parseEmbeddedEcho(p[icmpEchoHeadLen:], false)
+				if !ok || !dst.Equal(target) || embeddedID != id || embeddedSeq != seq {
+					continue
+				}
+				if p[1] == 4 { // 需要分片但设置了DF，字节6-7为下一跳MTU
+					return false, int(binary.BigEndian.Uint16(p[6:8])), nil
+				}
+				return false, 0, ErrPingHostUnreachable
+			}
+		}
+	}
+
+	// attempt 对同一尺寸重试，避免偶发丢包被误判为超过路径MTU
+	attempt := func(size int) (bool, int, error) {
+		for i := 0; i < mtuProbeAttempts; i++ {
+			ok, hint, err := probe(size)
+			if ok || hint > 0 || err != nil {
+				return ok, hint, err
+			}
+		}
+		return false, 0, nil
+	}
+
+	low, high := 0, opts.MaxMTU // low 为已确认可通过的最大尺寸
+	for high >= minPathMTU && low < high {
+		size := high
+		if low > 0 {
+			size = (low + high + 1) / 2
+		}
+		ok, hint, err := attempt(size)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			low = size
+			continue
+		}
+		high = size - 1
+		if hint >= minPathMTU && hint < high {
+			high = hint
+		}
+		if low == 0 {
+			// 尚无成功的尺寸，先确认最小MTU可达，否则目标本身无应答
+			if ok, _, err := attempt(minPathMTU); err != nil {
+				return 0, err
+			} else if !ok {
+				return 0, fmt.Errorf("最小尺寸报文也无应答，无法探测路径MTU")
+			}
+			low = minPathMTU
+		}
+	}
+	if low == 0 {
+		return 0, fmt.Errorf("未能确定路径MTU")
+	}
+	return low, nil
+}
@@ -0,0 +1,72 @@
+package phone
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wumitech-com/mdcp_server_operator/internal/config"
+)
+
+// NetworkHealth 云手机网络健康状态
+type NetworkHealth int
+
+const (
+	NetworkHealthUnknown     NetworkHealth = iota // 无法判定（如探测本身失败）
+	NetworkHealthHealthy                          // 健康
+	NetworkHealthDegraded                         // 降级: 延迟或丢包超过阈值
+	NetworkHealthUnreachable                      // 不可达
+)
+
+// String 返回健康状态名称
+func (h NetworkHealth) String() string {
+	switch h {
+	case NetworkHealthHealthy:
+		return "healthy"
+	case NetworkHealthDegraded:
+		return "degraded"
+	case NetworkHealthUnreachable:
+		return "unreachable"
+	default:
+		return "unknown"
+	}
+}
+
+// HealthThresholds 网络健康判定阈值
+type HealthThresholds struct {
+	Latency            time.Duration // 平均延迟超过该值判定为降级，0表示不按延迟判定
+	DegradedLossPct    float64       // 丢包率达到该值判定为降级，0表示不按丢包判定
+	UnreachableLossPct float64       // 丢包率达到该值判定为不可达，0表示仅全部丢包时不可达
+}
+
+// NewHealthThresholds 从云手机配置读取健康判定阈值
+func NewHealthThresholds(cfg config.PhoneConfig) HealthThresholds {
+	return HealthThresholds{
+		Latency:            time.Duration(cfg.LatencyThreshold * float64(time.Millisecond)),
+		DegradedLossPct:    cfg.DegradedLossThreshold,
+		UnreachableLossPct: cfg.UnreachableLossThreshold,
+	}
+}
+
+// ClassifyPingHealth 根据Ping统计判定网络健康状态，并返回判定原因
+func ClassifyPingHealth(stats *PingStats, thresholds HealthThresholds) (NetworkHealth, string) {
+	if stats == nil || stats.Sent == 0 {
+		return NetworkHealthUnknown, "未完成探测"
+	}
+
+	if stats.Received == 0 {
+		if stats.Unreachable > 0 {
+			return NetworkHealthUnreachable, "目标主机不可达"
+		}
+		return NetworkHealthUnreachable, "全部丢包"
+	}
+	if thresholds.UnreachableLossPct > 0 && stats.LossPercent >= thresholds.UnreachableLossPct {
+		return NetworkHealthUnreachable, fmt.Sprintf("丢包率%.1f%%达到不可达阈值%.1f%%", stats.LossPercent, thresholds.UnreachableLossPct)
+	}
+	if thresholds.DegradedLossPct > 0 && stats.LossPercent >= thresholds.DegradedLossPct {
+		return NetworkHealthDegraded, fmt.Sprintf("丢包率%.1f%%达到降级阈值%.1f%%", stats.LossPercent, thresholds.DegradedLossPct)
+	}
+	if thresholds.Latency > 0 && stats.Avg > thresholds.Latency {
+		return NetworkHealthDegraded, fmt.Sprintf("平均延迟%v超过阈值%v", stats.Avg.Round(time.Microsecond), thresholds.Latency)
+	}
+	return NetworkHealthHealthy, "延迟和丢包均在阈值内"
+}
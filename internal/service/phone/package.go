@@ -0,0 +1,227 @@
+package phone
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPackageInstallTimeout = 120                // 默认安装超时时间（秒）
+	defaultPackageQueryTimeout   = 30                 // 默认包查询/卸载超时时间（秒）
+	packageStagingDir            = "/data/local/tmp/" // APK临时上传目录
+)
+
+var (
+	// packageNameRe Android 包名格式
+	packageNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z0-9_]+)+$`)
+	// pmFailureRe pm 失败输出，如 "Failure [INSTALL_FAILED_VERSION_DOWNGRADE: Downgrade detected]"
+	pmFailureRe = regexp.MustCompile(`Failure \[([^\]:]+)(?::\s*([^\]]*))?\]`)
+	// pmFailureCodeRe pm 失败码格式，部分失败（如 "not installed for 0"）只有描述没有失败码
+	pmFailureCodeRe = regexp.MustCompile(`^[A-Z0-9_]+$`)
+	// dumpsysPackageRe dumpsys package 中的包段落头，如 "  Package [com.example] (1a2b3c):"
+	dumpsysPackageRe = regexp.MustCompile(`^\s*Package \[([^\]]+)\]`)
+)
+
+// PackageError pm 返回的结构化失败信息
+type PackageError struct {
+	Code    string // pm 失败码，如 INSTALL_FAILED_INSUFFICIENT_STORAGE
+	Message string // 失败详情
+}
+
+// Error 实现 error 接口
+func (e *PackageError) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// InstallOptions APK安装选项
+type InstallOptions struct {
+	Replace          bool // -r 覆盖安装
+	AllowDowngrade   bool // -d 允许降级
+	GrantPermissions bool // -g 授予全部运行时权限
+}
+
+// PackageInfo 已安装应用信息
+type PackageInfo struct {
+	PackageName string
+	VersionCode int64
+	VersionName string
+	System      bool
+	CodePath    string
+}
+
+// ValidatePackageName 校验包名格式，防止拼接到shell命令中
+func ValidatePackageName(packageName string) error {
+	if !packageNameRe.MatchString(packageName) {
+		return fmt.Errorf("无效的包名: %q", packageName)
+	}
+	return nil
+}
+
+// parsePMResult 解析 pm install/uninstall 输出，成功返回 nil，失败返回 *PackageError
+func parsePMResult(output string) error {
+	if strings.Contains(output, "Success") {
+		return nil
+	}
+	if m := pmFailureRe.FindStringSubmatch(output); m != nil {
+		if pmFailureCodeRe.MatchString(m[1]) {
+			return &PackageError{Code: m[1], Message: strings.TrimSpace(m[2])}
+		}
+		return &PackageError{Code: "FAILURE", Message: strings.Trim(strings.TrimPrefix(m[0], "Failure "), "[]")}
+	}
+
+	message := strings.TrimSpace(output)
+	if message == "" {
+		message = "pm 无输出"
+	}
+	return &PackageError{Code: "UNKNOWN", Message: message}
+}
+
+// InstallPackage 将 apk 上传到设备临时目录后执行 pm install，maxSize<=0 表示不限制大小
+func InstallPackage(ctx context.Context, ipAddress string, apk io.Reader, maxSize int64, expectedSHA256 string, opts InstallOptions, timeout int32, onProgress ProgressHandler) error {
+	if timeout <= 0 {
+		timeout = defaultPackageInstallTimeout
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("生成临时文件名失败: %v", err)
+	}
+	stagingPath := packageStagingDir + "mdcp_install_" + hex.EncodeToString(suffix) + ".apk"
+
+	if _, err := PushFile(ctx, ipAddress, stagingPath, 0o644, apk, maxSize, expectedSHA256, onProgress); err != nil {
+		removeRemoteFile(ctx, ipAddress, stagingPath)
+		return fmt.Errorf("上传APK失败: %v", err)
+	}
+	defer removeRemoteFile(context.Background(), ipAddress, stagingPath)
+
+	args := []string{"pm", "install"}
+	if opts.Replace {
+		args = append(args, "-r")
+	}
+	if opts.AllowDowngrade {
+		args = append(args, "-d")
+	}
+	if opts.GrantPermissions {
+		args = append(args, "-g")
+	}
+	args = append(args, shellQuote(stagingPath))
+
+	installCtx, installCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer installCancel()
+
+	stdout, stderr, _, err := runADBShell(installCtx, deviceAddress(ipAddress), strings.Join(args, " "))
+	if err != nil {
+		return fmt.Errorf("执行pm install失败: %v", err)
+	}
+	return parsePMResult(stdout + stderr)
+}
+
+// UninstallPackage 卸载应用，keepData 为 true 时保留数据和缓存
+func UninstallPackage(ctx context.Context, ipAddress, packageName string, keepData bool) error {
+	if err := ValidatePackageName(packageName); err != nil {
+		return err
+	}
+
+	command := "pm uninstall " + packageName
+	if keepData {
+		command = "pm uninstall -k " + packageName
+	}
+
+	connectDevice(ctx, deviceAddress(ipAddress))
+
+	uninstallCtx, uninstallCancel := context.WithTimeout(ctx, defaultPackageQueryTimeout*time.Second)
+	defer uninstallCancel()
+
+	stdout, stderr, _, err := runADBShell(uninstallCtx, deviceAddress(ipAddress), command)
+	if err != nil {
+		return fmt.Errorf("执行pm uninstall失败: %v", err)
+	}
+	return parsePMResult(stdout + stderr)
+}
+
+// ListPackages 列出已安装应用及版本信息，includeSystem 为 false 时过滤系统应用
+func ListPackages(ctx context.Context, ipAddress string, includeSystem bool) ([]PackageInfo, error) {
+	connectDevice(ctx, deviceAddress(ipAddress))
+
+	listCtx, listCancel := context.WithTimeout(ctx, defaultPackageQueryTimeout*time.Second)
+	defer listCancel()
+
+	// dumpsys 一次即可拿到所有包的 versionCode/versionName，避免逐个查询
+	stdout, stderr, exitCode, err := runADBShell(listCtx, deviceAddress(ipAddress), "dumpsys package packages")
+	if err != nil {
+		return nil, fmt.Errorf("执行dumpsys package失败: %v", err)
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("执行dumpsys package失败: ExitCode=%d, %s", exitCode, strings.TrimSpace(stderr))
+	}
+
+	packages := parseDumpsysPackages(stdout)
+	if includeSystem {
+		return packages, nil
+	}
+
+	filtered := packages[:0]
+	for _, pkg := range packages {
+		if !pkg.System {
+			filtered = append(filtered, pkg)
+		}
+	}
+	return filtered, nil
+}
+
+// parseDumpsysPackages 解析 dumpsys package packages 输出
+func parseDumpsysPackages(output string) []PackageInfo {
+	var (
+		packages []PackageInfo
+		current  *PackageInfo
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// 之后是被更新覆盖的系统包旧版本，不计入已安装列表
+		if strings.HasPrefix(strings.TrimSpace(line), "Hidden system packages:") {
+			break
+		}
+
+		if m := dumpsysPackageRe.FindStringSubmatch(line); m != nil {
+			packages = append(packages, PackageInfo{PackageName: m[1]})
+			current = &packages[len(packages)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "versionCode="):
+			// versionCode=123 minSdk=21 targetSdk=30
+			value := strings.Fields(strings.TrimPrefix(trimmed, "versionCode="))
+			if len(value) > 0 {
+				current.VersionCode, _ = strconv.ParseInt(value[0], 10, 64)
+			}
+		case strings.HasPrefix(trimmed, "versionName="):
+			current.VersionName = strings.TrimPrefix(trimmed, "versionName=")
+		case strings.HasPrefix(trimmed, "codePath="):
+			current.CodePath = strings.TrimPrefix(trimmed, "codePath=")
+		case strings.HasPrefix(trimmed, "pkgFlags="):
+			current.System = strings.Contains(trimmed, " SYSTEM ")
+		}
+	}
+
+	return packages
+}
@@ -0,0 +1,173 @@
+package phone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBootWaitTimeout = 180 // 默认等待开机完成超时时间（秒）
+	bootPollInterval       = 2 * time.Second
+	rebootCommandTimeout   = 10 * time.Second
+	shutdownGracePeriod    = 30 * time.Second // 重启后等待设备下线的最长时间
+)
+
+// RebootMode 重启模式
+type RebootMode int
+
+const (
+	RebootNormal     RebootMode = iota // 正常重启
+	RebootRecovery                     // 重启进入recovery
+	RebootBootloader                   // 重启进入bootloader
+)
+
+// ErrBootTimeout 等待开机完成超时
+var ErrBootTimeout = errors.New("等待开机超时")
+
+var (
+	// rebootUnreachableMarkers adb reboot 输出中表示命令未送达设备的错误
+	rebootUnreachableMarkers = []string{"failed to connect", "not found", "offline", "unauthorized", "no devices"}
+	// rebootDisconnectMarkers adb reboot 输出中表示设备执行重启时断开了连接的错误，命令已送达
+	rebootDisconnectMarkers = []string{"closed", "eof", "connection reset", "broken pipe", "protocol fault"}
+)
+
+// containsAnyMarker 输出中是否包含任一标记（不区分大小写）
+func containsAnyMarker(output string, markers []string) bool {
+	text := strings.ToLower(output)
+	for _, marker := range markers {
+		if strings.Contains(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// RebootPhone 重启设备，重启命令送达后连接断开属于正常现象，不视为失败
+func RebootPhone(ctx context.Context, ipAddress string, mode RebootMode) error {
+	deviceAddr := deviceAddress(ipAddress)
+	connectDevice(ctx, deviceAddr)
+
+	args := []string{"-s", deviceAddr, "reboot"}
+	switch mode {
+	case RebootNormal:
+	case RebootRecovery:
+		args = append(args, "recovery")
+	case RebootBootloader:
+		args = append(args, "bootloader")
+	default:
+		return fmt.Errorf("不支持的重启模式: %d", mode)
+	}
+
+	rebootCtx, rebootCancel := context.WithTimeout(ctx, rebootCommandTimeout)
+	defer rebootCancel()
+
+	output, err := exec.CommandContext(rebootCtx, "adb", args...).CombinedOutput()
+	if err != nil {
+		text := strings.TrimSpace(string(output))
+		switch {
+		case ctx.Err() != nil:
+			return fmt.Errorf("重启已取消: %v", ctx.Err())
+		case rebootCtx.Err() != nil:
+			// 超时无法确认命令是否送达，按失败处理
+			return fmt.Errorf("发送重启命令超时(%v)", rebootCommandTimeout)
+		case containsAnyMarker(text, rebootUnreachableMarkers):
+			return fmt.Errorf("发送重启命令失败: %s", text)
+		case !containsAnyMarker(text, rebootDisconnectMarkers):
+			return fmt.Errorf("发送重启命令失败: %v, %s", err, text)
+		}
+		// 设备执行重启时断开连接，命令已送达
+	}
+
+	// 旧连接已失效，断开后由后续命令重新连接
+	disconnectCtx, disconnectCancel := context.WithTimeout(ctx, defaultADBTimeout*time.Second)
+	defer disconnectCancel()
+	_, _ = exec.CommandContext(disconnectCtx, "adb", "disconnect", deviceAddr).CombinedOutput()
+
+	return nil
+}
+
+// bootCompleted 查询设备 sys.boot_completed，设备不可达时返回 false
+func bootCompleted(ctx context.Context, deviceAddr string) bool {
+	connectDevice(ctx, deviceAddr)
+
+	queryCtx, queryCancel := context.WithTimeout(ctx, defaultADBTimeout*time.Second)
+	defer queryCancel()
+
+	stdout, _, exitCode, err := runADBShell(queryCtx, deviceAddr, "getprop sys.boot_completed")
+	return err == nil && exitCode == 0 && strings.TrimSpace(stdout) == "1"
+}
+
+// WaitForBoot 轮询 sys.boot_completed 直到设备开机完成，返回等待时长
+func WaitForBoot(ctx context.Context, ipAddress string, timeout time.Duration) (time.Duration, error) {
+	if timeout <= 0 {
+		timeout = defaultBootWaitTimeout * time.Second
+	}
+
+	deviceAddr := deviceAddress(ipAddress)
+	start := time.Now()
+	waitCtx, waitCancel := context.WithTimeout(ctx, timeout)
+	defer waitCancel()
+
+	ticker := time.NewTicker(bootPollInterval)
+	defer ticker.Stop()
+	for {
+		if bootCompleted(waitCtx, deviceAddr) {
+			return time.Since(start), nil
+		}
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return time.Since(start), fmt.Errorf("等待开机已取消: %v", ctx.Err())
+			}
+			return time.Since(start), fmt.Errorf("%w(%v)", ErrBootTimeout, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitForShutdown 等待设备下线，避免重启命令生效前误判为已开机；超过宽限期仍在线时直接返回
+func waitForShutdown(ctx context.Context, deviceAddr string) {
+	graceCtx, graceCancel := context.WithTimeout(ctx, shutdownGracePeriod)
+	defer graceCancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-graceCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !bootCompleted(graceCtx, deviceAddr) {
+			return
+		}
+	}
+}
+
+// RebootAndWait 正常重启设备并等待开机完成，返回从发出重启到开机完成的时长
+func RebootAndWait(ctx context.Context, ipAddress string, timeout time.Duration) (time.Duration, error) {
+	if timeout <= 0 {
+		timeout = defaultBootWaitTimeout * time.Second
+	}
+
+	start := time.Now()
+	if err := RebootPhone(ctx, ipAddress, RebootNormal); err != nil {
+		return 0, err
+	}
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, timeout)
+	defer waitCancel()
+
+	waitForShutdown(waitCtx, deviceAddress(ipAddress))
+	if _, err := WaitForBoot(waitCtx, ipAddress, timeout); err != nil {
+		if ctx.Err() == nil && waitCtx.Err() == context.DeadlineExceeded {
+			return time.Since(start), fmt.Errorf("%w(%v)", ErrBootTimeout, timeout)
+		}
+		return time.Since(start), err
+	}
+	return time.Since(start), nil
+}
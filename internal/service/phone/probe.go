@@ -0,0 +1,212 @@
+package phone
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultProbeTimeout = 3 // 默认每个探测阶段的超时时间（秒）
+
+	adbCommandCNXN  = 0x4e584e43 // "CNXN"
+	adbCommandAUTH  = 0x48545541 // "AUTH"
+	adbVersion      = 0x01000001
+	adbMaxPayload   = 256 * 1024
+	adbHeaderLength = 24
+)
+
+// 探测阶段名称
+const (
+	ProbeStageICMP         = "icmp"
+	ProbeStageTCPConnect   = "tcp_connect"
+	ProbeStageADBHandshake = "adb_handshake"
+	ProbeStageADBEcho      = "adb_echo"
+)
+
+// ProbeStatus 探测阶段状态
+type ProbeStatus int
+
+const (
+	ProbeSkipped ProbeStatus = iota // 前置阶段失败或未请求，未执行
+	ProbeOK
+	ProbeFailed
+)
+
+// ProbeStage 单个探测阶段结果
+type ProbeStage struct {
+	Name    string
+	Status  ProbeStatus
+	Latency time.Duration
+	Detail  string // 成功时的补充信息（如握手应答类型）或失败原因
+}
+
+// ProbeResult 分阶段探测结果
+type ProbeResult struct {
+	Stages      []ProbeStage
+	FailedStage string // 第一个失败的阶段，全部成功时为空；ICMP失败不影响后续阶段
+}
+
+// ProbeOptions 探测选项
+type ProbeOptions struct {
+	ADBEcho  bool  // 是否通过ADB执行 echo ok
+	SkipICMP bool  // 跳过ICMP阶段（调用方已单独Ping时使用）
+	Timeout  int32 // 每个阶段的超时时间（秒）
+}
+
+// ProbePhone 依次探测ICMP、ADB端口TCP连接、adbd协议握手和可选的 echo ok，定位设备在哪一层不可用
+// 部分网络会屏蔽ICMP，因此ICMP失败时仍继续后续阶段；TCP或握手失败时跳过之后的阶段
+func ProbePhone(ctx context.Context, ipAddress string, opts ProbeOptions) *ProbeResult {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	stageTimeout := time.Duration(timeout) * time.Second
+	result := &ProbeResult{}
+
+	record := func(stage ProbeStage) {
+		if stage.Status == ProbeFailed && result.FailedStage == "" {
+			result.FailedStage = stage.Name
+		}
+		result.Stages = append(result.Stages, stage)
+	}
+
+	if opts.SkipICMP {
+		record(ProbeStage{Name: ProbeStageICMP, Status: ProbeSkipped})
+	} else {
+		record(probeICMP(ctx, ipAddress, timeout))
+	}
+
+	deviceAddr := deviceAddress(ipAddress)
+	tcpStage, conn := probeTCPConnect(ctx, deviceAddr, stageTimeout)
+	record(tcpStage)
+	if conn == nil {
+		record(ProbeStage{Name: ProbeStageADBHandshake, Status: ProbeSkipped})
+		record(ProbeStage{Name: ProbeStageADBEcho, Status: ProbeSkipped})
+		return result
+	}
+
+	handshake := probeADBHandshake(conn, stageTimeout)
+	conn.Close()
+	record(handshake)
+
+	if !opts.ADBEcho || handshake.Status != ProbeOK {
+		record(ProbeStage{Name: ProbeStageADBEcho, Status: ProbeSkipped})
+		return result
+	}
+	record(probeADBEcho(ctx, deviceAddr, stageTimeout))
+	return result
+}
+
+// probeICMP 单次ICMP回显
+func probeICMP(ctx context.Context, ipAddress string, timeout int32) ProbeStage {
+	stage := ProbeStage{Name: ProbeStageICMP}
+	stats, err := ExecutePing(ctx, ipAddress, PingOptions{Count: 1, ProbeTimeout: time.Duration(timeout) * time.Second})
+	if err != nil {
+		stage.Status, stage.Detail = ProbeFailed, err.Error()
+		return stage
+	}
+	stage.Status, stage.Latency = ProbeOK, stats.Avg
+	return stage
+}
+
+// probeTCPConnect 与ADB端口建立TCP连接，成功时返回连接供握手阶段使用
+func probeTCPConnect(ctx context.Context, deviceAddr string, timeout time.Duration) (ProbeStage, net.Conn) {
+	stage := ProbeStage{Name: ProbeStageTCPConnect}
+
+	dialCtx, dialCancel := context.WithTimeout(ctx, timeout)
+	defer dialCancel()
+
+	var dialer net.Dialer
+	start := time.Now()
+	conn, err := dialer.DialContext(dialCtx, "tcp", deviceAddr)
+	stage.Latency = time.Since(start)
+	if err != nil {
+		stage.Status, stage.Detail = ProbeFailed, err.Error()
+		return stage, nil
+	}
+	stage.Status = ProbeOK
+	return stage, conn
+}
+
+// probeADBHandshake 直接向adbd发送CNXN报文，收到AUTH或CNXN应答即说明adbd在正常处理协议
+func probeADBHandshake(conn net.Conn, timeout time.Duration) ProbeStage {
+	stage := ProbeStage{Name: ProbeStageADBHandshake}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		stage.Status, stage.Detail = ProbeFailed, err.Error()
+		return stage
+	}
+
+	start := time.Now()
+	if _, err := conn.Write(adbPacket(adbCommandCNXN, adbVersion, adbMaxPayload, []byte("host::\x00"))); err != nil {
+		stage.Status, stage.Detail = ProbeFailed, fmt.Sprintf("发送CNXN失败: %v", err)
+		return stage
+	}
+
+	header := make([]byte, adbHeaderLength)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		stage.Latency = time.Since(start)
+		stage.Status, stage.Detail = ProbeFailed, fmt.Sprintf("等待adbd应答失败: %v", err)
+		return stage
+	}
+	stage.Latency = time.Since(start)
+
+	command := binary.LittleEndian.Uint32(header[0:4])
+	magic := binary.LittleEndian.Uint32(header[20:24])
+	if magic != command^0xffffffff {
+		stage.Status, stage.Detail = ProbeFailed, "adbd应答格式无效"
+		return stage
+	}
+	switch command {
+	case adbCommandAUTH:
+		stage.Status, stage.Detail = ProbeOK, "AUTH"
+	case adbCommandCNXN:
+		stage.Status, stage.Detail = ProbeOK, "CNXN"
+	default:
+		stage.Status, stage.Detail = ProbeFailed, fmt.Sprintf("意外的adbd应答: %q", header[0:4])
+	}
+	return stage
+}
+
+// adbPacket 构造adb传输层报文
+func adbPacket(command, arg0, arg1 uint32, payload []byte) []byte {
+	var checksum uint32
+	for _, b := range payload {
+		checksum += uint32(b)
+	}
+
+	packet := make([]byte, adbHeaderLength, adbHeaderLength+len(payload))
+	binary.LittleEndian.PutUint32(packet[0:], command)
+	binary.LittleEndian.PutUint32(packet[4:], arg0)
+	binary.LittleEndian.PutUint32(packet[8:], arg1)
+	binary.LittleEndian.PutUint32(packet[12:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(packet[16:], checksum)
+	binary.LittleEndian.PutUint32(packet[20:], command^0xffffffff)
+	return append(packet, payload...)
+}
+
+// probeADBEcho 通过adb server执行 echo ok，验证授权和shell均可用
+func probeADBEcho(ctx context.Context, deviceAddr string, timeout time.Duration) ProbeStage {
+	stage := ProbeStage{Name: ProbeStageADBEcho}
+	connectDevice(ctx, deviceAddr)
+
+	echoCtx, echoCancel := context.WithTimeout(ctx, timeout)
+	defer echoCancel()
+
+	start := time.Now()
+	stdout, stderr, exitCode, err := runADBShell(echoCtx, deviceAddr, "echo ok")
+	stage.Latency = time.Since(start)
+	switch {
+	case err != nil:
+		stage.Status, stage.Detail = ProbeFailed, err.Error()
+	case exitCode != 0 || strings.TrimSpace(stdout) != "ok":
+		stage.Status, stage.Detail = ProbeFailed, strings.TrimSpace(stdout+stderr)
+	default:
+		stage.Status = ProbeOK
+	}
+	return stage
+}
@@ -0,0 +1,262 @@
+package phone
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultScreenshotTimeout   = 10  // 默认截图超时时间（秒）
+	defaultJPEGQuality         = 80  // 默认JPEG质量
+	defaultScreenRecordSeconds = 30  // 默认录屏时长（秒）
+	maxScreenRecordSeconds     = 180 // screenrecord 自身允许的最长录制时间（秒）
+)
+
+// ImageFormat 截图输出格式
+type ImageFormat int
+
+const (
+	ImageFormatPNG ImageFormat = iota
+	ImageFormatJPEG
+)
+
+// ScreenshotOptions 截图参数
+type ScreenshotOptions struct {
+	Format      ImageFormat
+	MaxWidth    int // 按比例缩小到不超过该宽度，0表示不限制
+	MaxHeight   int // 按比例缩小到不超过该高度，0表示不限制
+	JPEGQuality int
+}
+
+// Screenshot 截图结果
+type Screenshot struct {
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// RecordOptions 录屏参数
+type RecordOptions struct {
+	Duration time.Duration // 录制时长，受 maxDuration 限制
+	Width    int           // 视频宽度，0表示使用屏幕分辨率
+	Height   int           // 视频高度，0表示使用屏幕分辨率
+	BitRate  int           // 码率（bps），0表示使用设备默认值
+}
+
+// CaptureScreenshot 通过 screencap 截取设备屏幕，可选缩放和转码为JPEG
+func CaptureScreenshot(ctx context.Context, ipAddress string, opts ScreenshotOptions, timeout int32) (*Screenshot, error) {
+	if timeout <= 0 {
+		timeout = defaultScreenshotTimeout
+	}
+
+	deviceAddr := deviceAddress(ipAddress)
+	connectDevice(ctx, deviceAddr)
+
+	execCtx, execCancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer execCancel()
+
+	// exec-out 不经过PTY，二进制输出不会被换行转换破坏
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(execCtx, "adb", "-s", deviceAddr, "exec-out", "screencap -p")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("执行screencap失败: %v, %s", err, stderr.String())
+	}
+
+	img, err := png.Decode(&stdout)
+	if err != nil {
+		return nil, fmt.Errorf("解析截图失败: %v", err)
+	}
+
+	bounds := img.Bounds()
+	width, height := fitWithin(bounds.Dx(), bounds.Dy(), opts.MaxWidth, opts.MaxHeight)
+	if width != bounds.Dx() || height != bounds.Dy() {
+		img = downscaleImage(img, width, height)
+	}
+
+	var out bytes.Buffer
+	switch opts.Format {
+	case ImageFormatJPEG:
+		quality := opts.JPEGQuality
+		if quality <= 0 || quality > 100 {
+			quality = defaultJPEGQuality
+		}
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: quality})
+	default:
+		err = png.Encode(&out, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("编码截图失败: %v", err)
+	}
+
+	return &Screenshot{Data: out.Bytes(), Width: width, Height: height}, nil
+}
+
+// fitWithin 计算按比例缩小到不超过 maxWidth x maxHeight 的尺寸，不放大
+func fitWithin(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight {
+		if s := float64(maxHeight) / float64(height); s < scale {
+			scale = s
+		}
+	}
+	if scale >= 1 {
+		return width, height
+	}
+
+	w, h := int(float64(width)*scale), int(float64(height)*scale)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// downscaleImage 区域平均缩小图片
+func downscaleImage(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	}
+	srcW, srcH := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy0, sy1 := y*srcH/height, (y+1)*srcH/height
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < width; x++ {
+			sx0, sx1 := x*srcW/width, (x+1)*srcW/width
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, b, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				offset := rgba.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint32(rgba.Pix[offset])
+					g += uint32(rgba.Pix[offset+1])
+					b += uint32(rgba.Pix[offset+2])
+					a += uint32(rgba.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// RecordScreen 通过 screenrecord 录制屏幕，原始H.264码流到达即通过 onData 回调
+// 达到时长上限、字节上限（maxBytes>0）或 ctx 取消时停止；返回已录制字节数以及是否因字节上限被截断
+func RecordScreen(ctx context.Context, ipAddress string, opts RecordOptions, maxDuration time.Duration, maxBytes int64, onData func([]byte) error) (int64, bool, error) {
+	if maxDuration <= 0 || maxDuration > maxScreenRecordSeconds*time.Second {
+		maxDuration = maxScreenRecordSeconds * time.Second
+	}
+	duration := opts.Duration
+	if duration <= 0 {
+		duration = defaultScreenRecordSeconds * time.Second
+	}
+	if duration > maxDuration {
+		duration = maxDuration
+	}
+
+	deviceAddr := deviceAddress(ipAddress)
+	connectDevice(ctx, deviceAddr)
+
+	args := "screenrecord --output-format=h264 --time-limit " + strconv.Itoa(int(duration.Seconds()))
+	if opts.Width > 0 && opts.Height > 0 {
+		args += fmt.Sprintf(" --size %dx%d", opts.Width, opts.Height)
+	}
+	if opts.BitRate > 0 {
+		args += " --bit-rate " + strconv.Itoa(opts.BitRate)
+	}
+	args += " -"
+
+	// 设备侧 --time-limit 负责正常结束，这里额外留出余量兜底
+	execCtx, execCancel := context.WithTimeout(ctx, duration+10*time.Second)
+	defer execCancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(execCtx, "adb", "-s", deviceAddr, "exec-out", args)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, false, fmt.Errorf("创建stdout管道失败: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, false, fmt.Errorf("启动screenrecord失败: %v", err)
+	}
+
+	var (
+		recorded  int64
+		truncated bool
+		sendErr   error
+	)
+	buf := make([]byte, streamReadBufferSize)
+	for {
+		n, readErr := stdout.Read(buf)
+		if n > 0 {
+			if maxBytes > 0 && recorded+int64(n) > maxBytes {
+				n = int(maxBytes - recorded)
+				truncated = true
+			}
+			if n > 0 {
+				if sendErr = onData(buf[:n]); sendErr != nil {
+					break
+				}
+				recorded += int64(n)
+			}
+			if truncated {
+				break
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			sendErr = readErr
+			break
+		}
+	}
+
+	// 提前结束（截断、发送失败）时终止录制进程
+	execCancel()
+	waitErr := cmd.Wait()
+
+	if sendErr != nil {
+		return recorded, truncated, fmt.Errorf("转发录屏数据失败: %v", sendErr)
+	}
+	if ctx.Err() != nil {
+		return recorded, truncated, fmt.Errorf("录屏已取消: %v", ctx.Err())
+	}
+	if waitErr != nil && !truncated && recorded == 0 {
+		return recorded, truncated, fmt.Errorf("执行screenrecord失败: %v, %s", waitErr, stderr.String())
+	}
+	return recorded, truncated, nil
+}
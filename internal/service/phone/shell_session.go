@@ -0,0 +1,333 @@
+package phone
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultShellIdleTimeout = 600 // 默认交互式Shell空闲超时时间（秒）
+	defaultShellRows        = 24  // 默认终端行数
+	defaultShellCols        = 80  // 默认终端列数
+)
+
+// ErrShellIdleTimeout 交互式Shell会话空闲超时
+var ErrShellIdleTimeout = errors.New("shell会话空闲超时")
+
+// ShellSessionOptions 交互式Shell会话参数
+type ShellSessionOptions struct {
+	IPAddress     string
+	Rows          uint16
+	Cols          uint16
+	IdleTimeout   time.Duration // 客户端无输入（含调整窗口）超过该时长即关闭会话，设备输出不计入活跃
+	TranscriptDir string        // 会话记录目录，为空则不记录
+	TraceID       string        // 写入会话记录，便于审计时关联请求日志
+}
+
+// ShellSession 基于本地PTY的 adb shell 交互式会话
+// adb 客户端检测到终端后会在设备侧分配PTY，并在收到 SIGWINCH 时同步窗口大小
+type ShellSession struct {
+	ptmx        *os.File
+	cmd         *exec.Cmd
+	transcript  *shellTranscript
+	idleTimeout time.Duration
+	lastActive  atomic.Int64
+	idleExpired atomic.Bool
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// StartShellSession 连接设备并启动交互式 adb shell 会话
+func StartShellSession(opts ShellSessionOptions) (*ShellSession, error) {
+	ip := net.ParseIP(opts.IPAddress)
+	if ip == nil {
+		return nil, fmt.Errorf("IP地址无效: %q", opts.IPAddress)
+	}
+	opts.IPAddress = ip.String()
+
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaultShellIdleTimeout * time.Second
+	}
+	if opts.Rows == 0 || opts.Cols == 0 {
+		opts.Rows, opts.Cols = defaultShellRows, defaultShellCols
+	}
+
+	deviceAddr := deviceAddress(opts.IPAddress)
+	connectDevice(context.Background(), deviceAddr)
+
+	ptmx, tty, err := openPTY()
+	if err != nil {
+		return nil, fmt.Errorf("创建PTY失败: %v", err)
+	}
+	defer tty.Close()
+
+	if err := setWinsize(ptmx, opts.Rows, opts.Cols); err != nil {
+		ptmx.Close()
+		return nil, fmt.Errorf("设置终端大小失败: %v", err)
+	}
+
+	transcript, err := newShellTranscript(opts)
+	if err != nil {
+		ptmx.Close()
+		return nil, fmt.Errorf("创建会话记录失败: %v", err)
+	}
+
+	cmd := exec.Command("adb", "-s", deviceAddr, "shell")
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	// 新建会话并将PTY设为控制终端，使 adb 能收到窗口大小变化信号
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		ptmx.Close()
+		transcript.close()
+		return nil, fmt.Errorf("启动adb shell失败: %v", err)
+	}
+
+	s := &ShellSession{
+		ptmx:        ptmx,
+		cmd:         cmd,
+		transcript:  transcript,
+		idleTimeout: opts.IdleTimeout,
+		done:        make(chan struct{}),
+	}
+	s.touch()
+	transcript.record("start", fmt.Sprintf("device=%s rows=%d cols=%d", deviceAddr, opts.Rows, opts.Cols))
+
+	go func() {
+		_ = cmd.Wait()
+		close(s.done)
+	}()
+	go s.watchIdle()
+
+	return s, nil
+}
+
+// touch 刷新会话最近活跃时间，只由客户端输入和调整窗口调用
+// 设备输出不刷新，否则运行 top、logcat 等持续输出命令的会话永远不会空闲超时
+func (s *ShellSession) touch() {
+	s.lastActive.Store(time.Now().UnixNano())
+}
+
+// watchIdle 空闲超时后终止 adb shell 进程
+func (s *ShellSession) watchIdle() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, s.lastActive.Load())) > s.idleTimeout {
+				s.idleExpired.Store(true)
+				s.transcript.record("idle_timeout", s.idleTimeout.String())
+				_ = s.cmd.Process.Kill()
+				return
+			}
+		}
+	}
+}
+
+// Read 读取终端输出，进程退出后返回 io.EOF
+func (s *ShellSession) Read(p []byte) (int, error) {
+	n, err := s.ptmx.Read(p)
+	if n > 0 {
+		s.transcript.record("output", string(p[:n]))
+	}
+	if err != nil {
+		// 从端全部关闭后读主端返回 EIO，视为正常结束
+		if errors.Is(err, syscall.EIO) || errors.Is(err, os.ErrClosed) {
+			return n, io.EOF
+		}
+		return n, err
+	}
+	return n, nil
+}
+
+// Write 写入终端输入
+func (s *ShellSession) Write(p []byte) (int, error) {
+	s.touch()
+	s.transcript.record("input", string(p))
+	return s.ptmx.Write(p)
+}
+
+// Resize 调整终端窗口大小
+func (s *ShellSession) Resize(rows, cols uint16) error {
+	if rows == 0 || cols == 0 {
+		return fmt.Errorf("无效的终端大小: %dx%d", rows, cols)
+	}
+	s.touch()
+	s.transcript.record("resize", fmt.Sprintf("rows=%d cols=%d", rows, cols))
+	return setWinsize(s.ptmx, rows, cols)
+}
+
+// Done 返回会话结束通知
+func (s *ShellSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait 等待会话结束并返回退出码
+func (s *ShellSession) Wait() (int32, error) {
+	<-s.done
+	if s.idleExpired.Load() {
+		return -1, ErrShellIdleTimeout
+	}
+	exitCode := int32(s.cmd.ProcessState.ExitCode())
+	s.transcript.record("exit", fmt.Sprintf("exit_code=%d", exitCode))
+	return exitCode, nil
+}
+
+// Close 终止会话并释放PTY和会话记录
+func (s *ShellSession) Close() {
+	s.closeOnce.Do(func() {
+		select {
+		case <-s.done:
+		default:
+			_ = s.cmd.Process.Kill()
+			<-s.done
+		}
+		s.ptmx.Close()
+		s.transcript.record("close", "")
+		s.transcript.close()
+	})
+}
+
+// openPTY 打开一对伪终端，返回主端和从端
+func openPTY() (*os.File, *os.File, error) {
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ptyNum int
+	if err := controlFD(ptmx, func(fd int) error {
+		// 解锁从端并获取其编号
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+			return err
+		}
+		ptyNum, err = unix.IoctlGetInt(fd, unix.TIOCGPTN)
+		return err
+	}); err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+
+	tty, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", ptyNum), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	return ptmx, tty, nil
+}
+
+// setWinsize 设置终端窗口大小，内核会向前台进程组发送 SIGWINCH
+func setWinsize(f *os.File, rows, cols uint16) error {
+	return controlFD(f, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: rows, Col: cols})
+	})
+}
+
+// controlFD 在不改变文件阻塞模式的前提下对底层fd执行操作
+func controlFD(f *os.File, fn func(fd int) error) error {
+	rawConn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// shellTranscriptEntry 会话记录条目（JSON Lines）
+type shellTranscriptEntry struct {
+	Time    string `json:"time"`
+	TraceID string `json:"trace_id"`
+	Event   string `json:"event"`
+	Data    string `json:"data,omitempty"`
+}
+
+// shellTranscript 交互式Shell会话记录，用于事后审计
+type shellTranscript struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	traceID string
+}
+
+// newShellTranscript 创建会话记录文件，未配置目录时返回空记录器
+func newShellTranscript(opts ShellSessionOptions) (*shellTranscript, error) {
+	t := &shellTranscript{traceID: opts.TraceID}
+	if opts.TranscriptDir == "" {
+		return t, nil
+	}
+
+	if err := os.MkdirAll(opts.TranscriptDir, 0o750); err != nil {
+		return nil, err
+	}
+
+	traceID := opts.TraceID
+	if traceID == "" {
+		traceID = "notrace"
+	}
+	name := fmt.Sprintf("%s_%s_%s.jsonl",
+		time.Now().Format("20060102-150405"),
+		strings.ReplaceAll(opts.IPAddress, ":", "_"),
+		traceID,
+	)
+	file, err := os.OpenFile(filepath.Join(opts.TranscriptDir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	t.file = file
+	t.encoder = json.NewEncoder(file)
+	return t, nil
+}
+
+// record 追加一条会话记录，写入失败不影响会话本身
+func (t *shellTranscript) record(event, data string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.encoder == nil {
+		return
+	}
+	_ = t.encoder.Encode(shellTranscriptEntry{
+		Time:    time.Now().Format("2006-01-02 15:04:05.000"),
+		TraceID: t.traceID,
+		Event:   event,
+		Data:    data,
+	})
+}
+
+// close 关闭会话记录文件
+func (t *shellTranscript) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+		t.encoder = nil
+	}
+}
@@ -113,14 +113,14 @@ func (e *PortMappingExecutor) EnablePortMapping(ctx context.Context, internalIP
 	return nil
 }
 
-// DisablePortMapping 禁用端口映射
-func (e *PortMappingExecutor) DisablePortMapping(ctx context.Context, mappedPort int32) error {
+// DisablePortMapping 禁用端口映射，返回被删除规则的目标内网IP（未找到规则时为空）
+func (e *PortMappingExecutor) DisablePortMapping(ctx context.Context, mappedPort int32) (string, error) {
 	// 列出PHONE_PORT_MAPPING链的所有规则（带handle）
 	listCmd := fmt.Sprintf("--handle list chain %s %s", e.tableName, e.chainName)
 	output, err := e.executeNFTCommand(ctx, strings.Split(listCmd, " ")...)
 	if err != nil {
 		logger.WarnFWithContext(ctx, "查询nftables规则失败: %v", err)
-		return nil
+		return "", nil
 	}
 
 	// 解析输出，查找包含目标端口的规则handle
@@ -139,17 +139,32 @@ func (e *PortMappingExecutor) DisablePortMapping(ctx context.Context, mappedPort
 					_, err = e.executeNFTCommand(ctx, strings.Split(deleteCmd, " ")...)
 					if err != nil {
 						logger.ErrorFWithContext(ctx, "删除nftables规则失败: %v", err)
-						return fmt.Errorf("删除端口映射规则失败: %v", err)
+						return "", fmt.Errorf("删除端口映射规则失败: %v", err)
 					}
 					logger.InfoFWithContext(ctx, "端口映射已禁用: 端口 %d（handle: %s）", mappedPort, handle)
-					return nil
+					return dnatTarget(line), nil
 				}
 			}
 		}
 	}
 
 	logger.WarnFWithContext(ctx, "未找到端口 %d 的映射规则", mappedPort)
-	return nil
+	return "", nil
+}
+
+// dnatTarget 从规则行（如 "tcp dport 10196 dnat to 192.168.87.126:5555 # handle 5"）中提取DNAT目标IP
+func dnatTarget(rule string) string {
+	fields := strings.Fields(rule)
+	for i := 0; i+2 < len(fields); i++ {
+		if fields[i] == "dnat" && fields[i+1] == "to" {
+			target := fields[i+2]
+			if idx := strings.LastIndex(target, ":"); idx > 0 {
+				target = target[:idx]
+			}
+			return target
+		}
+	}
+	return ""
 }
 
 // ListPortMappings 列出所有端口映射
@@ -106,6 +106,11 @@ HOST_LOG_DIR="${HOME}/logs/server_operator_online"
 mkdir -p "${HOST_LOG_DIR}"
 chmod -R 777 "${HOST_LOG_DIR}" 2>/dev/null || true
 
+# ADB密钥目录（固定路径），镜像重建后云手机无需重新授权
+HOST_ADBKEY_DIR="${HOME}/adbkeys/server_operator_online"
+mkdir -p "${HOST_ADBKEY_DIR}"
+chmod 700 "${HOST_ADBKEY_DIR}" 2>/dev/null || true
+
 echo "[3/3] 以线上配置运行容器（带端口映射功能）"
 docker run -d \
   --name "${CONTAINER_NAME}" \
@@ -115,6 +120,7 @@ docker run -d \
   -p "${HOST_GRPC_PORT}:50058" \
   -v "${TEMP_RUNTIME_CONFIG}:${CONTAINER_CONFIG_MOUNT}:ro" \
   -v "${HOST_LOG_DIR}:/app/logs" \
+  -v "${HOST_ADBKEY_DIR}:/app/adbkeys" \
   -e "TZ=Asia/Shanghai" \
   -e "RUNTIME_CONFIG_PATH=${CONTAINER_CONFIG_MOUNT}" \
   --restart unless-stopped \
@@ -123,5 +129,6 @@ docker run -d \
 echo "✅ 启动完成。"
 echo "- gRPC: localhost:${HOST_GRPC_PORT}"
 echo "- 日志目录: ${HOST_LOG_DIR}"
+echo "- ADB密钥目录: ${HOST_ADBKEY_DIR}"
 echo "- 端口映射: 已启用（通过nsenter执行宿主机nftables）"
 
@@ -2,8 +2,11 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
+	"os"
 	"time"
 
 	"github.com/wumitech-com/mdcp_common/logger"
@@ -11,7 +14,9 @@ import (
 	"github.com/wumitech-com/mdcp_server_operator/internal/config"
 	"github.com/wumitech-com/mdcp_server_operator/internal/handlers"
 	"github.com/wumitech-com/mdcp_server_operator/internal/middleware"
+	"github.com/wumitech-com/mdcp_server_operator/internal/service/monitor"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
 )
 
@@ -42,20 +47,39 @@ func RunGRPCServer(ctx context.Context, cfg *config.Config) error {
 			middleware.RecoveryInterceptor,
 			middleware.TraceInterceptor,
 		)),
+		grpc.StreamInterceptor(middleware.ChainStreamServer(
+			middleware.RecoveryStreamInterceptor,
+			middleware.TraceStreamInterceptor,
+		)),
 		grpc.KeepaliveEnforcementPolicy(enforcementPolicy),
 		grpc.MaxRecvMsgSize(cfg.Server.GRPC.MaxReceiveMessageSize),
 		grpc.MaxSendMsgSize(cfg.Server.GRPC.MaxSendMessageSize),
 		grpc.KeepaliveParams(keepaliveParams),
 	}
 
+	if cfg.Server.GRPC.TLS.CertFile != "" {
+		creds, err := loadServerCredentials(cfg.Server.GRPC.TLS)
+		if err != nil {
+			return fmt.Errorf("加载TLS证书失败: %v", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+		logger.InfoF("gRPC已启用TLS, 校验客户端证书=%v", cfg.Server.GRPC.TLS.MutualTLSEnabled())
+	}
+
 	// 创建gRPC服务器
 	logger.InfoF("正在创建gRPC服务器...")
 	grpcServer := grpc.NewServer(opts...)
 	logger.InfoF("gRPC服务器创建成功")
 
+	// 创建云手机后台监测
+	phoneMonitor := monitor.NewMonitor(cfg.Monitor, cfg.Phone)
+
 	// 创建处理器
 	logger.InfoF("正在创建服务器操作处理器...")
-	handler := handlers.NewServerOperatorHandler(cfg)
+	handler, err := handlers.NewServerOperatorHandler(cfg, phoneMonitor)
+	if err != nil {
+		return fmt.Errorf("创建服务器操作处理器失败: %v", err)
+	}
 	logger.InfoF("服务器操作处理器创建成功")
 
 	// 注册服务
@@ -63,6 +87,9 @@ func RunGRPCServer(ctx context.Context, cfg *config.Config) error {
 	server_operator.RegisterServerOperatorServiceServer(grpcServer, handler)
 	logger.InfoF("gRPC服务注册成功")
 
+	// 启动云手机后台监测，随 ctx 取消退出
+	phoneMonitor.Start(ctx)
+
 	// 启动服务器
 	logger.InfoF("正在启动gRPC服务器...")
 	go func() {
@@ -84,3 +111,30 @@ func RunGRPCServer(ctx context.Context, cfg *config.Config) error {
 
 	return nil
 }
+
+// loadServerCredentials 加载服务端证书，配置了客户端CA时强制校验客户端证书
+func loadServerCredentials(cfg config.TLSConfig) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端CA失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("客户端CA文件中没有有效证书: %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}